@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// App holds what the demo handlers, the middleware tracing them and the
+// background worker share: their settings, tracer, meter and instruments, and
+// the state of the carts and the job queue. The handlers and middleware are
+// methods on it, so that an App can be built with its own configuration and
+// providers, e.g. in tests.
+type App struct {
+	cfg    config
+	tracer trace.Tracer
+	meter  metric.Meter
+
+	requestCounter          metric.Int64Counter
+	canceledCounter         metric.Int64Counter
+	errorCounter            metric.Int64Counter
+	latencyHistogram        metric.Float64Histogram
+	phaseHistogram          metric.Float64Histogram
+	clientDurationHistogram metric.Float64Histogram
+	// Only set when LATENCY_SUM_COUNTERS is enabled
+	latencySumCounter          metric.Float64Counter
+	latencyCountCounter        metric.Int64Counter
+	methodNotAllowedCounter    metric.Int64Counter
+	bodyBytesCounter           metric.Int64Counter
+	bodyTooLargeCounter        metric.Int64Counter
+	invalidTraceContextCounter metric.Int64Counter
+	itemGauge                  metric.Int64Gauge
+	cartSizeDeltaCounter       metric.Int64UpDownCounter
+	cartRejectedAddsCounter    metric.Int64Counter
+	jobsProcessedCounter       metric.Int64Counter
+	jobDurationHistogram       metric.Float64Histogram
+	jobQueueWaitHistogram      metric.Float64Histogram
+
+	// Instruments reported by the telemetry pipeline itself, which
+	// initTraceProvider hands to its span processors
+	spanAttributeCountHistogram metric.Int64Histogram
+	// Spans that were ended but never exported, reported at shutdown
+	droppedSpansCounter metric.Int64Counter
+	// Traces the errors-only processor gave up on before they completed
+	evictedTracesCounter metric.Int64Counter
+	shutdownCounter      metric.Int64Counter
+
+	// latencyTuner, when not nil, backs latencyHistogram with auto-tuned
+	// buckets.
+	latencyTuner *bucketTuner
+
+	// downstreamClient makes the calls of /chain.
+	downstreamClient *http.Client
+
+	// jobs buffers jobs waiting for the worker.
+	jobs      chan job
+	lastJobID atomic.Int64
+
+	// carts holds the number of items in each user's cart. A cart is deleted
+	// as soon as it empties, so the map only holds carts that are in use and
+	// can't grow with every user that ever visited.
+	cartsMu sync.Mutex
+	carts   map[string]int64
+	// cartChurn is the net cart activity (adds minus removes) since start. It
+	// is never reset: exporters report api.cart.churn with delta temporality,
+	// so each reader gets the change since its own previous collection.
+	cartChurn atomic.Int64
+}
+
+// NewApp returns an App configured by cfg, creating its instruments with
+// meter. When latencyTuner is not nil, it records the request latencies.
+// Each App registers its own observable instruments, so Apps should not share
+// a meter provider.
+func NewApp(cfg config, tracer trace.Tracer, meter metric.Meter, latencyTuner *bucketTuner) (*App, error) {
+	a := &App{
+		cfg:          cfg,
+		tracer:       tracer,
+		meter:        meter,
+		latencyTuner: latencyTuner,
+		jobs:         make(chan job, 100),
+		carts:        map[string]int64{},
+	}
+	if err := a.registerInstruments(); err != nil {
+		return nil, err
+	}
+	a.downstreamClient = newDownstreamClient(a.clientDurationHistogram)
+	return a, nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// backgroundTaskHandler starts fire-and-forget work and responds immediately.
+// The work is traced in a new root span, so its trace isn't tied to the
+// lifetime of the request, with a link back to the request that started it.
+func (a *App) backgroundTaskHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	if !a.requireMethod(w, r, span, http.MethodPost) {
+		return
+	}
+
+	// Keep request-scoped values but not the request's cancellation
+	go a.runBackgroundTask(context.WithoutCancel(ctx))
+
+	w.WriteHeader(http.StatusAccepted)
+	_, _ = w.Write([]byte("Background task started."))
+}
+
+// runBackgroundTask simulates detached work started by a request.
+func (a *App) runBackgroundTask(ctx context.Context) {
+	_, span := a.tracer.Start(ctx, "backgroundTask",
+		trace.WithNewRoot(),
+		trace.WithLinks(trace.LinkFromContext(ctx,
+			attribute.String("link.reason", "started_by"),
+		)),
+	)
+	defer span.End()
+
+	// Simulate 100-500ms of work
+	time.Sleep(time.Duration(100+rand.IntN(400)) * time.Millisecond)
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestBackgroundTaskLinksRequest(t *testing.T) {
+	app, spans, _ := newTestApp(t, config{})
+
+	ctx, request := app.tracer.Start(context.Background(), "request")
+	app.runBackgroundTask(ctx)
+	request.End()
+
+	task := endedSpan(t, spans, "backgroundTask")
+	requestTrace := request.SpanContext().TraceID()
+	if task.SpanContext().TraceID() == requestTrace {
+		t.Error("background task shares the request's trace, want a new one")
+	}
+	if task.Parent().IsValid() {
+		t.Errorf("background task has parent %v, want a root span", task.Parent())
+	}
+	links := task.Links()
+	if len(links) != 1 || !links[0].SpanContext.Equal(request.SpanContext()) {
+		t.Errorf("background task links = %v, want a link to the request span", links)
+	}
+}
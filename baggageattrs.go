@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+)
+
+// baggageSpanKeys are the baggage members copied onto server spans, so that a
+// request tagged once upstream is tagged on every span downstream.
+var baggageSpanKeys = splitList(envString("BAGGAGE_SPAN_ATTRIBUTES", "user.id,tenant.id"))
+
+// baggageAttributes returns an attribute for each of baggageSpanKeys present in
+// the baggage of ctx.
+func baggageAttributes(ctx context.Context) []attribute.KeyValue {
+	b := baggage.FromContext(ctx)
+
+	var attrs []attribute.KeyValue
+	for _, key := range baggageSpanKeys {
+		if member := b.Member(key); member.Key() != "" {
+			attrs = append(attrs, attribute.String(key, member.Value()))
+		}
+	}
+	return attrs
+}
+
+// splitList splits a comma-separated list, dropping empty entries.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestBaggageAttributes(t *testing.T) {
+	app, spans, _ := newTestApp(t, config{})
+	usePropagator(t)
+	server, _, err := NewServer(app, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("baggage", "user.id=42,session.id=abc")
+	server.Handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	attrs := attribute.NewSet(endedSpan(t, spans, "/").Attributes()...)
+	if got, _ := attrs.Value("user.id"); got.AsString() != "42" {
+		t.Errorf("user.id = %q, want %q", got.AsString(), "42")
+	}
+	if attrs.HasValue("tenant.id") {
+		t.Error("tenant.id is set without a baggage member")
+	}
+	if attrs.HasValue("session.id") {
+		t.Error("session.id is copied although it isn't in BAGGAGE_SPAN_ATTRIBUTES")
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	got := splitList(" user.id, ,tenant.id,")
+	if len(got) != 2 || got[0] != "user.id" || got[1] != "tenant.id" {
+		t.Errorf("splitList = %q, want [user.id tenant.id]", got)
+	}
+}
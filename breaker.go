@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// exportBreaker stops recording spans while the collector is unreachable, so
+// that no work is spent on telemetry that can't be delivered. It is disabled
+// unless CIRCUIT_BREAKER_THRESHOLD is set.
+var exportBreaker = &circuitBreaker{
+	threshold: envInt("CIRCUIT_BREAKER_THRESHOLD", 0),
+	cooldown:  envDuration("CIRCUIT_BREAKER_COOLDOWN", 30*time.Second),
+}
+
+// circuitBreaker opens once an exporter has failed threshold span exports in
+// a row. Streaks are counted per exporter, and so per collector, so that one
+// collector's successes don't hide another's outage. While open, recording is
+// skipped until cooldown has passed; recording then resumes and the next span
+// export acts as the probe, closing the breaker on success and reopening it on
+// failure. Only span exports feed the breaker, as only span recording is
+// paused.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu sync.Mutex
+	// failures holds the current streak of failed exports of each exporter
+	failures map[sdktrace.SpanExporter]int
+	openedAt time.Time
+}
+
+// allow reports whether telemetry should be recorded.
+func (b *circuitBreaker) allow() bool {
+	if b.threshold <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, failures := range b.failures {
+		if failures >= b.threshold {
+			return time.Since(b.openedAt) >= b.cooldown
+		}
+	}
+	return true
+}
+
+// record updates the breaker with the outcome of a span export by exporter.
+func (b *circuitBreaker) record(exporter sdktrace.SpanExporter, err error) {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		if b.failures[exporter] >= b.threshold {
+			log.Println("Exports recovered, resuming span recording")
+		}
+		delete(b.failures, exporter)
+		return
+	}
+
+	if b.failures == nil {
+		b.failures = map[sdktrace.SpanExporter]int{}
+	}
+	b.failures[exporter]++
+	if failures := b.failures[exporter]; failures >= b.threshold {
+		if failures == b.threshold {
+			log.Printf("%d consecutive exports failed, pausing span recording for %s: %v", failures, b.cooldown, err)
+		}
+		b.openedAt = time.Now()
+	}
+}
+
+// breakerSampler drops every span while the breaker is open and defers to
+// base otherwise.
+type breakerSampler struct {
+	base    sdktrace.Sampler
+	breaker *circuitBreaker
+}
+
+func (s breakerSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if !s.breaker.allow() {
+		return sdktrace.SamplingResult{
+			Decision:   sdktrace.Drop,
+			Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+		}
+	}
+	return s.base.ShouldSample(p)
+}
+
+func (s breakerSampler) Description() string {
+	return fmt.Sprintf("BreakerSampler{%s}", s.base.Description())
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var errCollectorUnavailable = errors.New("collector unavailable")
+
+// failingMetricExporter fails every export.
+type failingMetricExporter struct {
+	sdkmetric.Exporter
+}
+
+func (failingMetricExporter) Export(context.Context, *metricdata.ResourceMetrics) error {
+	return errCollectorUnavailable
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	const cooldown = 20 * time.Millisecond
+	breaker := &circuitBreaker{threshold: 3, cooldown: cooldown}
+	sampler := breakerSampler{base: sdktrace.AlwaysSample(), breaker: breaker}
+	sampled := func() bool {
+		return sampler.ShouldSample(sdktrace.SamplingParameters{}).Decision == sdktrace.RecordAndSample
+	}
+	exporter := tracetest.NewInMemoryExporter()
+
+	// Failures below the threshold don't trip the breaker
+	breaker.record(exporter, errCollectorUnavailable)
+	breaker.record(exporter, errCollectorUnavailable)
+	if !sampled() {
+		t.Fatal("breaker tripped before the threshold")
+	}
+
+	// Sustained failure trips it
+	breaker.record(exporter, errCollectorUnavailable)
+	if sampled() {
+		t.Fatal("breaker did not trip after sustained export failures")
+	}
+
+	// After the cooldown, spans are recorded again so the next export can
+	// probe the collector
+	time.Sleep(cooldown)
+	if !sampled() {
+		t.Fatal("breaker did not allow a probe after the cooldown")
+	}
+
+	// A failed probe reopens it
+	breaker.record(exporter, errCollectorUnavailable)
+	if sampled() {
+		t.Fatal("breaker did not reopen after a failed probe")
+	}
+
+	// A successful export closes it
+	time.Sleep(cooldown)
+	breaker.record(exporter, nil)
+	if !sampled() {
+		t.Fatal("breaker did not close after exports recovered")
+	}
+	breaker.record(exporter, errCollectorUnavailable)
+	if !sampled() {
+		t.Fatal("breaker tripped on a single failure after recovering")
+	}
+}
+
+func TestCircuitBreakerCountsPerExporter(t *testing.T) {
+	breaker := &circuitBreaker{threshold: 2, cooldown: time.Minute}
+	failing, healthy := tracetest.NewInMemoryExporter(), tracetest.NewInMemoryExporter()
+
+	// Successes on another collector don't reset the failing one's streak
+	breaker.record(failing, errCollectorUnavailable)
+	breaker.record(healthy, nil)
+	breaker.record(failing, errCollectorUnavailable)
+	if breaker.allow() {
+		t.Error("breaker did not trip after one collector failed twice in a row")
+	}
+}
+
+func TestCircuitBreakerIgnoresMetricExports(t *testing.T) {
+	defer func(orig *circuitBreaker) { exportBreaker = orig }(exportBreaker)
+	exportBreaker = &circuitBreaker{threshold: 1, cooldown: time.Minute}
+
+	exporter := &trackingMetricExporter{Exporter: failingMetricExporter{}}
+	for range 3 {
+		if err := exporter.Export(context.Background(), &metricdata.ResourceMetrics{}); err == nil {
+			t.Fatal("export unexpectedly succeeded")
+		}
+	}
+	if !exportBreaker.allow() {
+		t.Error("failed metric exports tripped the breaker")
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// canary marks this instance as a canary deployment, so that its telemetry
+// can be compared against the baseline.
+var canary = envBool("CANARY", false)
+
+var canaryAttribute = attribute.Bool("deployment.canary", true)
+
+// canaryProcessor tags every span with deployment.canary=true.
+type canaryProcessor struct{ noopProcessor }
+
+func (canaryProcessor) OnStart(_ context.Context, s sdktrace.ReadWriteSpan) {
+	s.SetAttributes(canaryAttribute)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestCanaryAttribute(t *testing.T) {
+	defer func(orig bool) { canary = orig }(canary)
+	canary = true
+	ctx := context.Background()
+
+	spans := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(canaryProcessor{}),
+		sdktrace.WithSpanProcessor(spans),
+	)
+	defer func() { _ = tp.Shutdown(ctx) }()
+
+	_, span := tp.Tracer("test").Start(ctx, "span")
+	span.End()
+
+	ended := spans.Ended()
+	if len(ended) != 1 {
+		t.Fatalf("got %d spans, want 1", len(ended))
+	}
+	if attrs := attribute.NewSet(ended[0].Attributes()...); !attrs.HasValue(canaryAttribute.Key) {
+		t.Errorf("span attributes %v have no %s", ended[0].Attributes(), canaryAttribute.Key)
+	}
+
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer func() { _ = mp.Shutdown(ctx) }()
+
+	counter, err := mp.Meter("test").Int64Counter("requests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter.Add(ctx, 1, withMetricAttributes(attribute.String("http.route", "/")))
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	dp := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64]).DataPoints[0]
+	if value, ok := dp.Attributes.Value(canaryAttribute.Key); !ok || !value.AsBool() {
+		t.Errorf("measurement attributes %v have no %s=true", dp.Attributes.ToSlice(), canaryAttribute.Key)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestMetricCardinalityLimit(t *testing.T) {
+	t.Setenv("METRIC_CARDINALITY_LIMIT", "3")
+	// Restored along with METRIC_CARDINALITY_LIMIT when the test ends
+	t.Setenv("OTEL_GO_X_CARDINALITY_LIMIT", "")
+	if err := applyMetricCardinalityLimit(); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer func() { _ = mp.Shutdown(ctx) }()
+	counter, err := mp.Meter("test").Int64Counter("requests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 10 {
+		counter.Add(ctx, 1, metric.WithAttributes(attribute.String("user", strconv.Itoa(i))))
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	dataPoints := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64]).DataPoints
+	if len(dataPoints) != 3 {
+		t.Errorf("got %d data points, want 3", len(dataPoints))
+	}
+	var total int64
+	var overflow bool
+	for _, dp := range dataPoints {
+		total += dp.Value
+		if value, ok := dp.Attributes.Value("otel.metric.overflow"); ok && value.AsBool() {
+			overflow = true
+		}
+	}
+	if !overflow {
+		t.Error("no otel.metric.overflow data point was collected")
+	}
+	if total != 10 {
+		t.Errorf("measurements add up to %d, want 10", total)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import "net/http"
+
+// cartUserHeader names the user whose cart a request changes. Requests
+// without it share the anonymous cart.
+const cartUserHeader = "X-User-ID"
+
+// cartUser returns the user whose cart r changes.
+func cartUser(r *http.Request) string {
+	if user := r.Header.Get(cartUserHeader); user != "" {
+		return user
+	}
+	return "anonymous"
+}
+
+// addCartItem adds an item to user's cart unless it is full, and returns its
+// resulting size.
+func (a *App) addCartItem(user string) (count int64, added bool) {
+	a.cartsMu.Lock()
+	defer a.cartsMu.Unlock()
+
+	if a.cfg.cartMaxItems > 0 && a.carts[user] >= a.cfg.cartMaxItems {
+		return a.carts[user], false
+	}
+	a.carts[user]++
+	a.cartChurn.Add(1)
+	return a.carts[user], true
+}
+
+// removeCartItem removes an item from user's cart unless it is empty, and
+// returns its resulting size.
+func (a *App) removeCartItem(user string) (count int64, removed bool) {
+	a.cartsMu.Lock()
+	defer a.cartsMu.Unlock()
+
+	if a.carts[user] == 0 {
+		return 0, false
+	}
+	a.cartChurn.Add(-1)
+
+	count = a.carts[user] - 1
+	if count == 0 {
+		delete(a.carts, user)
+		return 0, true
+	}
+	a.carts[user] = count
+	return count, true
+}
+
+// clearCart empties user's cart and returns how many items it held.
+func (a *App) clearCart(user string) int64 {
+	a.cartsMu.Lock()
+	defer a.cartsMu.Unlock()
+
+	cleared := a.carts[user]
+	delete(a.carts, user)
+	a.cartChurn.Add(-cleared)
+	return cleared
+}
+
+// activeCarts returns the number of non-empty carts.
+func (a *App) activeCarts() int64 {
+	a.cartsMu.Lock()
+	defer a.cartsMu.Unlock()
+
+	return int64(len(a.carts))
+}
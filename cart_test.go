@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// collectInt64Gauge collects reader and returns the value of the named gauge.
+func collectInt64Gauge(t *testing.T, reader sdkmetric.Reader, name string) int64 {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+			gauge, ok := m.Data.(metricdata.Gauge[int64])
+			if !ok {
+				t.Fatalf("%s is a %T, want an int64 gauge", name, m.Data)
+			}
+			if len(gauge.DataPoints) != 1 {
+				t.Fatalf("%s has %d data points, want 1", name, len(gauge.DataPoints))
+			}
+			return gauge.DataPoints[0].Value
+		}
+	}
+	t.Fatalf("no %s metric was collected", name)
+	return 0
+}
+
+func TestActiveCartCount(t *testing.T) {
+	app, _, reader := newTestApp(t, config{})
+
+	cartRequest := func(handler http.HandlerFunc, target, user string) {
+		r := httptest.NewRequest(http.MethodPost, target, nil)
+		r.Header.Set(cartUserHeader, user)
+		handler(httptest.NewRecorder(), r)
+	}
+
+	users := []string{"alice", "bob"}
+	for _, user := range users {
+		cartRequest(app.cartAddHandler, "/cart/add", user)
+	}
+	if n := collectInt64Gauge(t, reader, "api.cart.active_count"); n != 2 {
+		t.Errorf("after adding to two carts, api.cart.active_count = %d, want 2", n)
+	}
+
+	for _, user := range users {
+		cartRequest(app.cartRemoveHandler, "/cart/remove", user)
+	}
+	if n := collectInt64Gauge(t, reader, "api.cart.active_count"); n != 0 {
+		t.Errorf("after emptying both carts, api.cart.active_count = %d, want 0", n)
+	}
+	app.cartsMu.Lock()
+	defer app.cartsMu.Unlock()
+	if len(app.carts) != 0 {
+		t.Errorf("empty carts were kept: %v", app.carts)
+	}
+}
+
+func TestCartChurn(t *testing.T) {
+	// Two readers, as with OTEL_SECONDARY_ENDPOINT
+	first := sdkmetric.NewManualReader(sdkmetric.WithTemporalitySelector(metricTemporality))
+	second := sdkmetric.NewManualReader(sdkmetric.WithTemporalitySelector(metricTemporality))
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(first), sdkmetric.WithReader(second))
+	defer func() { _ = mp.Shutdown(context.Background()) }()
+	app, err := NewApp(config{}, noop.NewTracerProvider().Tracer("test"), mp.Meter("test"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, handler := range []http.HandlerFunc{app.cartAddHandler, app.cartAddHandler, app.cartAddHandler, app.cartRemoveHandler} {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+		}
+	}
+
+	if churn := collectInt64Sum(t, first, "api.cart.churn"); churn != 2 {
+		t.Errorf("churn = %d, want 2", churn)
+	}
+	if churn := collectInt64Sum(t, second, "api.cart.churn"); churn != 2 {
+		t.Errorf("churn seen by the second reader = %d, want 2", churn)
+	}
+	// Nothing happened since the previous collection
+	if churn := collectInt64Sum(t, first, "api.cart.churn"); churn != 0 {
+		t.Errorf("churn in an idle interval = %d, want 0", churn)
+	}
+}
+
+func TestCartConcurrentAddRemove(t *testing.T) {
+	app, _, _ := newTestApp(t, config{})
+	server, _, err := NewServer(app, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Run with -race to catch unsynchronized access to the carts
+	const workers = 8
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				server.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/cart/add", nil))
+				server.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/cart/remove", nil))
+			}
+		}()
+	}
+	wg.Wait()
+
+	if count := app.activeCarts(); count != 0 {
+		t.Errorf("%d carts are left after matched adds and removes, want 0", count)
+	}
+}
+
+func TestCartMaxItems(t *testing.T) {
+	app, _, reader := newTestApp(t, config{cartMaxItems: 2})
+
+	var codes []int
+	for range 3 {
+		rec := httptest.NewRecorder()
+		app.cartAddHandler(rec, httptest.NewRequest(http.MethodPost, "/cart/add", nil))
+		codes = append(codes, rec.Code)
+	}
+	if want := []int{http.StatusOK, http.StatusOK, http.StatusConflict}; !slices.Equal(codes, want) {
+		t.Errorf("statuses = %v, want %v", codes, want)
+	}
+	if n := collectInt64Gauge(t, reader, "api.cart.items"); n != 2 {
+		t.Errorf("api.cart.items = %d, want the cap of 2", n)
+	}
+	if n := collectInt64Sum(t, reader, "api.cart.rejected_adds"); n != 1 {
+		t.Errorf("api.cart.rejected_adds = %d, want 1", n)
+	}
+
+	// The cap applies to each cart on its own
+	r := httptest.NewRequest(http.MethodPost, "/cart/add", nil)
+	r.Header.Set(cartUserHeader, "alice")
+	rec := httptest.NewRecorder()
+	app.cartAddHandler(rec, r)
+	if rec.Code != http.StatusOK {
+		t.Errorf("adding to another cart: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestCartClear(t *testing.T) {
+	app, spans, reader := newTestApp(t, config{})
+	server, _, err := NewServer(app, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 3 {
+		server.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/cart/add", nil))
+	}
+	if items := collectInt64Gauge(t, reader, "api.cart.items"); items != 3 {
+		t.Fatalf("api.cart.items = %d, want 3", items)
+	}
+	if delta := collectInt64Sum(t, reader, "api.cart.size_delta"); delta != 3 {
+		t.Errorf("api.cart.size_delta = %d, want 3", delta)
+	}
+
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/cart/clear", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if items := collectInt64Gauge(t, reader, "api.cart.items"); items != 0 {
+		t.Errorf("api.cart.items after clearing = %d, want 0", items)
+	}
+	if churn := app.cartChurn.Load(); churn != 0 {
+		t.Errorf("churn after clearing = %d, want 0", churn)
+	}
+	if delta := collectInt64Sum(t, reader, "api.cart.size_delta"); delta != 0 {
+		t.Errorf("api.cart.size_delta after clearing = %d, want 0", delta)
+	}
+	attrs := attribute.NewSet(endedSpan(t, spans, "/cart/clear").Attributes()...)
+	if got, _ := attrs.Value("cartClearHandler.clearedCount"); got.AsInt64() != 3 {
+		t.Errorf("cartClearHandler.clearedCount = %v, want 3", got.Emit())
+	}
+}
+
+func TestCartSpanEvents(t *testing.T) {
+	app, spans, _ := newTestApp(t, config{cartMaxItems: 1})
+
+	steps := []struct {
+		handler http.HandlerFunc
+		name    string
+		want    []string
+	}{
+		{app.cartAddHandler, "add", []string{"validating cart", "item added", "gauge.recorded"}},
+		{app.cartAddHandler, "add when full", []string{"validating cart", "cart full"}},
+		{app.cartRemoveHandler, "remove", []string{"validating cart", "item removed", "gauge.recorded"}},
+		{app.cartRemoveHandler, "remove when empty", []string{"validating cart", "cart empty", "gauge.recorded"}},
+		{app.cartClearHandler, "clear", []string{"validating cart", "cart cleared", "gauge.recorded"}},
+	}
+	for _, step := range steps {
+		app.otelMiddleware(step.handler, step.name)(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", nil))
+
+		var got []string
+		for _, event := range endedSpan(t, spans, step.name).Events() {
+			got = append(got, event.Name)
+		}
+		if !slices.Equal(got, step.want) {
+			t.Errorf("%s: events = %q, want %q", step.name, got, step.want)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// selfURL returns the URL of the / route of a server listening on addr,
+// reached over loopback when listening on all interfaces.
+func selfURL(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "http://localhost:8080/"
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port) + "/"
+}
+
+// chainHandler calls the configured chain URL and reports the downstream
+// status.
+func (a *App) chainHandler(w http.ResponseWriter, r *http.Request) {
+	url := a.cfg.chainURL
+	if url == "" {
+		url = selfURL(a.cfg.addr)
+	}
+	status, err := a.callDownstream(r.Context(), url)
+	if err != nil {
+		http.Error(w, "Bad Gateway", http.StatusBadGateway)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, _ = fmt.Fprintf(w, "Downstream responded with %d.", status)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestChainInjectsTraceContext(t *testing.T) {
+	usePropagator(t)
+	var traceparent string
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer downstream.Close()
+	app, spans, _ := newTestApp(t, config{chainURL: downstream.URL})
+
+	rec := httptest.NewRecorder()
+	app.otelMiddleware(app.chainHandler, "/chain")(rec, httptest.NewRequest(http.MethodGet, "/chain", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	chain := endedSpan(t, spans, "/chain")
+	client := endedSpan(t, spans, "callDownstream")
+	if client.Parent().SpanID() != chain.SpanContext().SpanID() {
+		t.Error("callDownstream span isn't a child of the /chain span")
+	}
+	if got := parseTraceparent(traceparent); got.TraceID() != client.SpanContext().TraceID() || got.SpanID() != client.SpanContext().SpanID() {
+		t.Errorf("downstream got traceparent %q, want the callDownstream span", traceparent)
+	}
+}
+
+func TestChainContinuesTrace(t *testing.T) {
+	usePropagator(t)
+	ts := httptest.NewUnstartedServer(nil)
+	defer ts.Close()
+	app, spans, _ := newTestApp(t, config{chainURL: "http://" + ts.Listener.Addr().String() + "/"})
+	server, _, err := NewServer(app, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Config.Handler = server.Handler
+	ts.Start()
+
+	resp, err := http.Get(ts.URL + "/chain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	client := endedSpan(t, spans, "callDownstream")
+	downstream := endedSpan(t, spans, "/")
+	if downstream.Parent().SpanID() != client.SpanContext().SpanID() {
+		t.Error("the / span isn't a child of the callDownstream span")
+	}
+}
+
+func TestSelfURL(t *testing.T) {
+	tests := []struct {
+		addr, want string
+	}{
+		{":8080", "http://localhost:8080/"},
+		{"0.0.0.0:9000", "http://localhost:9000/"},
+		{"[::]:9000", "http://localhost:9000/"},
+		{"127.0.0.1:9000", "http://127.0.0.1:9000/"},
+		{"api.internal:80", "http://api.internal:80/"},
+	}
+	for _, tt := range tests {
+		if got := selfURL(tt.addr); got != tt.want {
+			t.Errorf("selfURL(%q) = %q, want %q", tt.addr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newDownstreamClient returns a client for calls to other services. Its
+// transport records client-side latency in histogram, mirroring the
+// server-side latency histogram.
+func newDownstreamClient(histogram metric.Float64Histogram) *http.Client {
+	return &http.Client{
+		Transport: clientMetricsTransport{base: http.DefaultTransport, histogram: histogram},
+		Timeout:   10 * time.Second,
+	}
+}
+
+// clientMetricsTransport records the duration of each outgoing request in the
+// http.client.duration_seconds histogram, tagged with the peer and outcome.
+type clientMetricsTransport struct {
+	base      http.RoundTripper
+	histogram metric.Float64Histogram
+}
+
+func (t clientMetricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+
+	attrs := []attribute.KeyValue{
+		attribute.String("server.address", req.URL.Host),
+		attribute.String("http.method", req.Method),
+	}
+	if err == nil {
+		attrs = append(attrs, attribute.Int("http.response.status_code", resp.StatusCode))
+	} else {
+		attrs = append(attrs, attribute.String("error.type", "transport"))
+	}
+	t.histogram.Record(req.Context(), time.Since(start).Seconds(), withMetricAttributes(attrs...))
+
+	return resp, err
+}
+
+// callDownstream GETs url in a client span and returns the response status.
+// The W3C trace context is injected into the request headers so that the
+// downstream service continues the same trace.
+func (a *App) callDownstream(ctx context.Context, url string) (int, error) {
+	ctx, span := a.tracer.Start(ctx, "callDownstream", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		recordError(span, err)
+		span.SetStatus(codes.Error, "invalid downstream request")
+		return 0, err
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	span.SetAttributes(
+		attribute.String("http.method", req.Method),
+		attribute.String("server.address", req.URL.Host),
+	)
+
+	resp, err := a.downstreamClient.Do(req)
+	if err != nil {
+		recordError(span, err)
+		span.SetStatus(codes.Error, "downstream request failed")
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+	if resp.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, fmt.Sprintf("downstream responded with %d", resp.StatusCode))
+	}
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestDownstreamClientDuration(t *testing.T) {
+	app, _, reader := newTestApp(t, config{})
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer downstream.Close()
+
+	resp, err := app.downstreamClient.Get(downstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusTeapot {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusTeapot)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "http.client.duration_seconds" {
+				continue
+			}
+			dp := m.Data.(metricdata.Histogram[float64]).DataPoints[0]
+			if dp.Count != 1 {
+				t.Errorf("recorded %d calls, want 1", dp.Count)
+			}
+			want := map[string]string{
+				"server.address":            downstream.Listener.Addr().String(),
+				"http.method":               http.MethodGet,
+				"http.response.status_code": "418",
+			}
+			for key, value := range want {
+				if got, _ := dp.Attributes.Value(attribute.Key(key)); got.Emit() != value {
+					t.Errorf("%s = %q, want %q", key, got.Emit(), value)
+				}
+			}
+			return
+		}
+	}
+	t.Error("no http.client.duration_seconds metric was collected")
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultServiceName is reported when OTEL_SERVICE_NAME and -service are
+// unset.
+const defaultServiceName = "test-service"
+
+// config holds the settings of an App. Those that can be given as
+// command-line flags override the environment, which overrides the built-in
+// defaults.
+type config struct {
+	serviceName    string
+	endpoint       string
+	addr           string
+	metricInterval time.Duration
+	// insecure is nil unless -insecure was given, in which case it overrides
+	// the per-endpoint default of otlpInsecure.
+	insecure *bool
+
+	// The remaining settings only come from the environment.
+
+	// secondaryEndpoint is a second collector that receives a copy of all
+	// telemetry, e.g. while migrating backends. Empty disables dual-writing.
+	secondaryEndpoint string
+	// adminAddr is the address of a separate server for probes and debug
+	// routes. When empty, those routes are served alongside the API.
+	adminAddr string
+	// debug enables endpoints for inspecting telemetry without a backend.
+	debug bool
+	// debugToken is the bearer token /debug/flush requires. The endpoint
+	// refuses every request while it is empty.
+	debugToken string
+	// chainURL is the service /chain calls. When empty, /chain calls this
+	// server's own / route, so that a single request produces a multi-span
+	// trace.
+	chainURL string
+	// simulatedLatency is the average time helloWorldHandler spends working,
+	// so demos and load tests get a realistic latency distribution.
+	simulatedLatency time.Duration
+	// simulatedErrorRate is the probability that helloWorldHandler fails.
+	simulatedErrorRate float64
+	// cartMaxItems caps the number of items in each cart. 0 means no limit.
+	cartMaxItems int64
+}
+
+// parseFlags parses the command-line arguments args, using the environment
+// for defaults.
+func parseFlags(args []string) config {
+	cfg := config{
+		serviceName:    envString("OTEL_SERVICE_NAME", defaultServiceName),
+		endpoint:       envString("OTEL_EXPORTER_OTLP_ENDPOINT", defaultEndpoint(otlpProtocol)),
+		addr:           envString("HTTP_ADDR", ":8080"),
+		metricInterval: envMillisDuration("OTEL_METRIC_EXPORT_INTERVAL", time.Minute),
+
+		secondaryEndpoint:  os.Getenv("OTEL_SECONDARY_ENDPOINT"),
+		adminAddr:          os.Getenv("ADMIN_ADDR"),
+		debug:              envBool("DEBUG", false),
+		debugToken:         os.Getenv("DEBUG_TOKEN"),
+		chainURL:           os.Getenv("CHAIN_URL"),
+		simulatedLatency:   time.Duration(envInt("SIMULATED_LATENCY_MS", 0)) * time.Millisecond,
+		simulatedErrorRate: loadSimulatedErrorRate(),
+		cartMaxItems:       int64(envInt("CART_MAX_ITEMS", 0)),
+	}
+
+	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags.StringVar(&cfg.serviceName, "service", cfg.serviceName, "service name (OTEL_SERVICE_NAME)")
+	flags.StringVar(&cfg.endpoint, "endpoint", cfg.endpoint, "collector endpoint as host:port (OTEL_EXPORTER_OTLP_ENDPOINT)")
+	flags.StringVar(&cfg.addr, "addr", cfg.addr, "address the API server listens on (HTTP_ADDR)")
+	flags.DurationVar(&cfg.metricInterval, "metric-interval", cfg.metricInterval, "how often metrics are exported (OTEL_METRIC_EXPORT_INTERVAL)")
+	flags.BoolFunc("insecure", "connect to the collector without TLS (OTEL_EXPORTER_OTLP_INSECURE)", func(value string) error {
+		insecure, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		cfg.insecure = &insecure
+		return nil
+	})
+	_ = flags.Parse(args)
+
+	cfg.endpoint = normalizeEndpoint(cfg.endpoint)
+	cfg.secondaryEndpoint = normalizeEndpoint(cfg.secondaryEndpoint)
+	return cfg
+}
+
+// loadSimulatedErrorRate reads SIMULATED_ERROR_RATE, clamped to 0..1.
+func loadSimulatedErrorRate() float64 {
+	rate := envFloat("SIMULATED_ERROR_RATE", 0.5)
+	clamped := min(max(rate, 0), 1)
+	if clamped != rate {
+		log.Printf("SIMULATED_ERROR_RATE %v is out of range, using %v", rate, clamped)
+	}
+	return clamped
+}
+
+// loadConfig applies the process-wide settings of cfg and logs the result.
+func loadConfig(cfg config) {
+	insecureFlag = cfg.insecure
+
+	if stdoutExport {
+		log.Printf("Using service name %q and exporting telemetry to stdout", cfg.serviceName)
+		return
+	}
+	log.Printf("Using service name %q and collector endpoint %q over %s", cfg.serviceName, cfg.endpoint, otlpProtocol)
+	if len(exportHeaders) > 0 {
+		log.Printf("Sending OTLP headers %s", redactedHeaders(exportHeaders))
+	}
+	if exportRetry.Enabled {
+		log.Printf("Retrying failed exports for up to %s", exportRetry.MaxElapsedTime)
+	} else {
+		log.Printf("Retrying failed exports is disabled")
+	}
+}
+
+// normalizeEndpoint drops the scheme and trailing slash from endpoints written
+// for the OTLP/HTTP convention, since the exporters take host:port.
+func normalizeEndpoint(endpoint string) string {
+	endpoint = strings.TrimPrefix(endpoint, "http://")
+	endpoint = strings.TrimPrefix(endpoint, "https://")
+	return strings.TrimSuffix(endpoint, "/")
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseFlagsNormalizesEndpoints(t *testing.T) {
+	t.Setenv("OTEL_SERVICE_NAME", "checkout")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4317/")
+	t.Setenv("OTEL_SECONDARY_ENDPOINT", "https://backup:4317/")
+
+	cfg := parseFlags(nil)
+
+	if cfg.serviceName != "checkout" {
+		t.Errorf("serviceName = %q, want %q", cfg.serviceName, "checkout")
+	}
+	if cfg.endpoint != "collector:4317" {
+		t.Errorf("endpoint = %q, want %q", cfg.endpoint, "collector:4317")
+	}
+	if cfg.secondaryEndpoint != "backup:4317" {
+		t.Errorf("secondaryEndpoint = %q, want %q", cfg.secondaryEndpoint, "backup:4317")
+	}
+}
+
+func TestParseFlagsDefaultEndpoint(t *testing.T) {
+	defer func(protocol string) { otlpProtocol = protocol }(otlpProtocol)
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+
+	tests := map[string]string{
+		protocolGRPC:         "localhost:4317",
+		protocolHTTPProtobuf: "localhost:4318",
+	}
+	for protocol, want := range tests {
+		otlpProtocol = protocol
+		if got := parseFlags(nil).endpoint; got != want {
+			t.Errorf("with protocol %s, endpoint = %q, want %q", protocol, got, want)
+		}
+	}
+}
+
+func TestParseFlagsOverrideEnvironment(t *testing.T) {
+	t.Setenv("OTEL_SERVICE_NAME", "checkout")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "collector:4317")
+	t.Setenv("OTEL_METRIC_EXPORT_INTERVAL", "30000")
+
+	cfg := parseFlags(nil)
+	if cfg.metricInterval != 30*time.Second {
+		t.Errorf("metricInterval = %s, want %s", cfg.metricInterval, 30*time.Second)
+	}
+	if cfg.insecure != nil {
+		t.Errorf("insecure = %t without -insecure, want unset", *cfg.insecure)
+	}
+
+	cfg = parseFlags([]string{
+		"-service", "payments",
+		"-endpoint", "https://other:4317",
+		"-addr", ":8081",
+		"-metric-interval", "5s",
+		"-insecure",
+	})
+	want := config{serviceName: "payments", endpoint: "other:4317", addr: ":8081", metricInterval: 5 * time.Second}
+	if cfg.serviceName != want.serviceName || cfg.endpoint != want.endpoint || cfg.addr != want.addr || cfg.metricInterval != want.metricInterval {
+		t.Errorf("parseFlags = %+v, want %+v", cfg, want)
+	}
+	if cfg.insecure == nil || !*cfg.insecure {
+		t.Error("-insecure didn't set insecure")
+	}
+}
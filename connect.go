@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// collectorConnectTimeout bounds how long waitForCollector retries.
+var collectorConnectTimeout = envDuration("COLLECTOR_CONNECT_TIMEOUT", 30*time.Second)
+
+// By default the app connects to collectors in the background and starts
+// serving right away. WAIT_FOR_COLLECTOR holds startup until they are
+// reachable instead, so that e.g. under docker compose, where the app may come
+// up first, the first exports aren't lost. If a collector is still unreachable
+// after collectorConnectTimeout, the app exits when COLLECTOR_REQUIRED is set
+// and starts degraded otherwise.
+var (
+	waitForCollectorOnStart = envBool("WAIT_FOR_COLLECTOR", false)
+	collectorRequired       = envBool("COLLECTOR_REQUIRED", false)
+)
+
+// connectCollectors connects to targets, in the background unless
+// waitForCollectorOnStart is set. It returns an error when waiting, a
+// collector is unreachable and collectorRequired is set.
+func connectCollectors(ctx context.Context, targets []otlpTarget) error {
+	if !waitForCollectorOnStart {
+		for _, target := range targets {
+			if target.conn != nil {
+				go waitForCollector(ctx, target.conn, collectorConnectTimeout)
+			}
+		}
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	reachable := make([]bool, len(targets))
+	for i, target := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if target.conn != nil {
+				reachable[i] = waitForCollector(ctx, target.conn, collectorConnectTimeout)
+			} else {
+				reachable[i] = waitForEndpoint(ctx, target.endpoint, collectorConnectTimeout)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, target := range targets {
+		if !reachable[i] && collectorRequired {
+			return fmt.Errorf("collector at %s is not reachable after %s", target.endpoint, collectorConnectTimeout)
+		}
+	}
+	return nil
+}
+
+// waitForCollector connects conn and waits for it to become ready, retrying
+// with exponential backoff until timeout passes. It reports whether the
+// collector became reachable; if not, exports fail until it does.
+func waitForCollector(ctx context.Context, conn *grpc.ClientConn, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	backoff := 500 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		conn.Connect()
+
+		state := conn.GetState()
+		log.Printf("Connecting to collector at %s (attempt %d, state %s)", conn.Target(), attempt, state)
+
+		attemptCtx, cancelAttempt := context.WithTimeout(ctx, backoff)
+		for state != connectivity.Ready && conn.WaitForStateChange(attemptCtx, state) {
+			state = conn.GetState()
+		}
+		cancelAttempt()
+
+		if state == connectivity.Ready {
+			log.Printf("Connected to collector at %s", conn.Target())
+			return true
+		}
+		if ctx.Err() != nil {
+			log.Printf("WARNING: collector at %s is not reachable after %s (state %s); telemetry will be lost until it is", conn.Target(), timeout, state)
+			return false
+		}
+
+		backoff = min(2*backoff, 10*time.Second)
+	}
+}
+
+// waitForEndpoint is waitForCollector for OTLP/HTTP collectors, which have no
+// gRPC connection to watch: it dials endpoint over TCP until it accepts a
+// connection.
+func waitForEndpoint(ctx context.Context, endpoint string, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	backoff := 500 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		log.Printf("Connecting to collector at %s (attempt %d)", endpoint, attempt)
+
+		attemptCtx, cancelAttempt := context.WithTimeout(ctx, backoff)
+		conn, err := dialer.DialContext(attemptCtx, "tcp", endpoint)
+		if err == nil {
+			cancelAttempt()
+			_ = conn.Close()
+			log.Printf("Connected to collector at %s", endpoint)
+			return true
+		}
+		// Wait out the rest of the attempt when the dial failed fast
+		<-attemptCtx.Done()
+		cancelAttempt()
+
+		if ctx.Err() != nil {
+			log.Printf("WARNING: collector at %s is not reachable after %s: %v; telemetry will be lost until it is", endpoint, timeout, err)
+			return false
+		}
+
+		backoff = min(2*backoff, 10*time.Second)
+	}
+}
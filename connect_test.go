@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestWaitForCollector(t *testing.T) {
+	ctx := context.Background()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if !waitForCollector(ctx, conn, 5*time.Second) {
+		t.Error("waitForCollector gave up on a reachable collector")
+	}
+
+	// Nothing listens on a port that was just released
+	lis, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().String()
+	_ = lis.Close()
+
+	conn, err = grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	const timeout = 200 * time.Millisecond
+	start := time.Now()
+	if waitForCollector(ctx, conn, timeout) {
+		t.Error("waitForCollector reported an unreachable collector as ready")
+	}
+	if elapsed := time.Since(start); elapsed > timeout+time.Second {
+		t.Errorf("waitForCollector returned after %s, want about %s", elapsed, timeout)
+	}
+}
+
+func TestConnectCollectorsRequired(t *testing.T) {
+	defer func(wait, required bool, timeout time.Duration) {
+		waitForCollectorOnStart, collectorRequired, collectorConnectTimeout = wait, required, timeout
+	}(waitForCollectorOnStart, collectorRequired, collectorConnectTimeout)
+	waitForCollectorOnStart = true
+	collectorConnectTimeout = 200 * time.Millisecond
+	ctx := context.Background()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	// Nothing listens on a port that was just released
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable := closed.Addr().String()
+	_ = closed.Close()
+
+	tests := []struct {
+		name     string
+		required bool
+		targets  []otlpTarget
+		wantErr  bool
+	}{
+		{name: "reachable", required: true, targets: []otlpTarget{{endpoint: lis.Addr().String()}}},
+		{name: "unreachable", required: true, targets: []otlpTarget{{endpoint: lis.Addr().String()}, {endpoint: unreachable}}, wantErr: true},
+		{name: "degraded", targets: []otlpTarget{{endpoint: unreachable}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collectorRequired = tt.required
+			if err := connectCollectors(ctx, tt.targets); (err != nil) != tt.wantErr {
+				t.Errorf("connectCollectors = %v, want error: %t", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// correlateHandler demonstrates span.AddLink: the related trace only becomes
+// known while handling the request, so the link is added after the span has
+// started rather than through trace.WithLinks. The request body is a W3C
+// traceparent, e.g.
+//
+//	00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func (a *App) correlateHandler(w http.ResponseWriter, r *http.Request) {
+	span := trace.SpanFromContext(r.Context())
+
+	if !a.requireMethod(w, r, span, http.MethodPost) {
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1024))
+	if err != nil {
+		a.bodyReadError(w, r, span, err)
+		return
+	}
+
+	related := parseTraceparent(strings.TrimSpace(string(body)))
+	if !related.IsValid() {
+		span.SetStatus(codes.Error, "invalid traceparent")
+		http.Error(w, "Body must be a valid traceparent", http.StatusBadRequest)
+		return
+	}
+
+	span.AddLink(trace.Link{
+		SpanContext: related,
+		Attributes:  []attribute.KeyValue{attribute.String("link.reason", "correlate")},
+	})
+
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("Linked to trace " + related.TraceID().String()))
+}
+
+// parseTraceparent returns the span context encoded in a traceparent value,
+// which is invalid if the value can't be parsed. It extracts into an empty
+// context so that the caller's active span can't be mistaken for the result.
+func parseTraceparent(traceparent string) trace.SpanContext {
+	carrier := propagation.MapCarrier{"traceparent": traceparent}
+	ctx := propagation.TraceContext{}.Extract(context.Background(), carrier)
+	return trace.SpanContextFromContext(ctx)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// startLinksProcessor records how many links each span had when it started.
+type startLinksProcessor struct {
+	links map[string]int
+}
+
+func (p startLinksProcessor) OnStart(_ context.Context, s sdktrace.ReadWriteSpan) {
+	p.links[s.Name()] = len(s.Links())
+}
+
+func (startLinksProcessor) OnEnd(sdktrace.ReadOnlySpan) {}
+
+func (startLinksProcessor) Shutdown(context.Context) error { return nil }
+
+func (startLinksProcessor) ForceFlush(context.Context) error { return nil }
+
+func TestCorrelateAddsLinkAfterStart(t *testing.T) {
+	app, _, _ := newTestApp(t, config{})
+
+	ctx := context.Background()
+	started := startLinksProcessor{links: map[string]int{}}
+	spans := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(started), sdktrace.WithSpanProcessor(spans))
+	defer func() { _ = tp.Shutdown(ctx) }()
+	app.tracer = tp.Tracer("test")
+
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	rec := httptest.NewRecorder()
+	app.otelMiddleware(app.correlateHandler, "correlateHandler")(rec, httptest.NewRequest(http.MethodPost, "/correlate", strings.NewReader(traceparent)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	if n := started.links["correlateHandler"]; n != 0 {
+		t.Errorf("span started with %d links, want none", n)
+	}
+	links := endedSpan(t, spans, "correlateHandler").Links()
+	if len(links) != 1 {
+		t.Fatalf("span ended with %d links, want 1", len(links))
+	}
+	if got, want := links[0].SpanContext, parseTraceparent(traceparent); !got.Equal(want) {
+		t.Errorf("link = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// correlationIDHeader carries a business correlation ID, which unlike the
+// trace ID can span several traces (e.g. retries of the same order).
+const correlationIDHeader = "X-Correlation-ID"
+
+// correlationIDKey is the attribute set on every span started in a request.
+const correlationIDKey = attribute.Key("app.correlation_id")
+
+type correlationIDContextKey struct{}
+
+// withCorrelationID returns a copy of ctx carrying id.
+func withCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDContextKey{}, id)
+}
+
+// correlationIDFromContext returns the correlation ID carried by ctx, or ""
+// if there is none.
+func correlationIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDContextKey{}).(string)
+	return id
+}
+
+// newCorrelationID generates a random correlation ID.
+func newCorrelationID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// withCorrelation takes the correlation ID from the request header, or
+// generates one, stores it in the request context and echoes it back.
+func withCorrelation(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(correlationIDHeader)
+		if id == "" {
+			id = newCorrelationID()
+		}
+		w.Header().Set(correlationIDHeader, id)
+		next(w, r.WithContext(withCorrelationID(r.Context(), id)))
+	}
+}
+
+// correlationIDProcessor tags every span started within a request with the
+// request's correlation ID.
+type correlationIDProcessor struct{ noopProcessor }
+
+func (correlationIDProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	if id := correlationIDFromContext(parent); id != "" {
+		s.SetAttributes(correlationIDKey.String(id))
+	}
+}
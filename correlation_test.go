@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestCorrelationIDOnEverySpan(t *testing.T) {
+	app, _, _ := newTestApp(t, config{})
+	spans := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(correlationIDProcessor{}),
+		sdktrace.WithSpanProcessor(spans),
+	)
+	defer func() { _ = tp.Shutdown(context.Background()) }()
+	app.tracer = tp.Tracer("test")
+
+	rec := httptest.NewRecorder()
+	app.withMiddleware(app.helloWorldHandler)(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	id := rec.Header().Get(correlationIDHeader)
+	if id == "" {
+		t.Fatal("no correlation ID was echoed back")
+	}
+
+	// The handler span and its phase spans
+	ended := spans.Ended()
+	if len(ended) < 2 {
+		t.Fatalf("got %d spans, want the handler span and its children", len(ended))
+	}
+	for _, span := range ended {
+		var got string
+		for _, kv := range span.Attributes() {
+			if kv.Key == correlationIDKey {
+				got = kv.Value.AsString()
+			}
+		}
+		if got != id {
+			t.Errorf("span %s has correlation ID %q, want %q", span.Name(), got, id)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// countingMetricExporter counts the exports it receives.
+type countingMetricExporter struct {
+	sdkmetric.Exporter
+	exports atomic.Int64
+}
+
+func (e *countingMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	e.exports.Add(1)
+	return e.Exporter.Export(ctx, rm)
+}
+
+func TestDebugFlush(t *testing.T) {
+	resetProviders(t)
+	ctx := context.Background()
+
+	// Nothing would be exported for an hour without a flush
+	spanExporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sdktrace.NewBatchSpanProcessor(spanExporter,
+		sdktrace.WithBatchTimeout(time.Hour),
+	)))
+	defer func() { _ = tp.Shutdown(ctx) }()
+	stdout, err := stdoutmetric.New(stdoutmetric.WithWriter(io.Discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	metricExporter := &countingMetricExporter{Exporter: stdout}
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter,
+		sdkmetric.WithInterval(time.Hour),
+	)))
+	defer func() { _ = mp.Shutdown(ctx) }()
+	if err := installTracerProvider(ctx, tp); err != nil {
+		t.Fatal(err)
+	}
+	if err := installMeterProvider(ctx, mp); err != nil {
+		t.Fatal(err)
+	}
+
+	_, span := tp.Tracer("test").Start(ctx, "span")
+	span.End()
+
+	app, _, _ := newTestApp(t, config{debug: true, debugToken: "test-token"})
+	server, _, err := NewServer(app, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flush := func(token string) int {
+		r := httptest.NewRequest(http.MethodPost, "/debug/flush", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		server.Handler.ServeHTTP(rec, r)
+		return rec.Code
+	}
+
+	if code := flush("wrong-token"); code != http.StatusUnauthorized {
+		t.Errorf("flush with a wrong token: status = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if n := len(spanExporter.GetSpans()); n != 0 {
+		t.Fatalf("%d spans were exported before the flush", n)
+	}
+
+	if code := flush(app.cfg.debugToken); code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", code, http.StatusOK)
+	}
+	if n := len(spanExporter.GetSpans()); n != 1 {
+		t.Errorf("%d spans were exported by the flush, want 1", n)
+	}
+	if n := metricExporter.exports.Load(); n != 1 {
+		t.Errorf("metrics were exported %d times by the flush, want 1", n)
+	}
+}
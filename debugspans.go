@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// recentSpans keeps the most recently ended spans for /debug/spans.
+var recentSpans = newSpanRing(envInt("DEBUG_SPANS_SIZE", 100))
+
+// recentSpan is the JSON form of an ended span.
+type recentSpan struct {
+	Name         string            `json:"name"`
+	TraceID      string            `json:"trace_id"`
+	SpanID       string            `json:"span_id"`
+	ParentSpanID string            `json:"parent_span_id,omitempty"`
+	DurationMs   float64           `json:"duration_ms"`
+	Status       string            `json:"status"`
+	Attributes   map[string]string `json:"attributes,omitempty"`
+}
+
+// spanRing is a span processor that keeps the last size ended spans in a
+// bounded ring buffer.
+type spanRing struct {
+	mu    sync.Mutex
+	spans []recentSpan
+	next  int
+	full  bool
+}
+
+func newSpanRing(size int) *spanRing {
+	if size < 1 {
+		size = 1
+	}
+	return &spanRing{spans: make([]recentSpan, size)}
+}
+
+func (r *spanRing) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+func (r *spanRing) OnEnd(s sdktrace.ReadOnlySpan) {
+	span := recentSpan{
+		Name:       s.Name(),
+		TraceID:    s.SpanContext().TraceID().String(),
+		SpanID:     s.SpanContext().SpanID().String(),
+		DurationMs: float64(s.EndTime().Sub(s.StartTime()).Microseconds()) / 1000,
+		Status:     s.Status().Code.String(),
+	}
+	if s.Parent().IsValid() {
+		span.ParentSpanID = s.Parent().SpanID().String()
+	}
+	if attrs := s.Attributes(); len(attrs) > 0 {
+		span.Attributes = make(map[string]string, len(attrs))
+		for _, kv := range attrs {
+			span.Attributes[string(kv.Key)] = kv.Value.Emit()
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.spans[r.next] = span
+	r.next = (r.next + 1) % len(r.spans)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+func (r *spanRing) Shutdown(context.Context) error { return nil }
+
+func (r *spanRing) ForceFlush(context.Context) error { return nil }
+
+// snapshot returns the buffered spans, oldest first.
+func (r *spanRing) snapshot() []recentSpan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.full {
+		return append([]recentSpan(nil), r.spans[:r.next]...)
+	}
+	return append(append([]recentSpan(nil), r.spans[r.next:]...), r.spans[:r.next]...)
+}
+
+// debugSpansHandler returns the recently ended spans as JSON.
+func debugSpansHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(recentSpans.snapshot())
+}
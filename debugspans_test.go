@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestDebugSpans(t *testing.T) {
+	app, _, _ := newTestApp(t, config{debug: true})
+	defer func(orig *spanRing) { recentSpans = orig }(recentSpans)
+	recentSpans = newSpanRing(2)
+
+	spans := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recentSpans), sdktrace.WithSpanProcessor(spans))
+	defer func() { _ = tp.Shutdown(context.Background()) }()
+	app.tracer = tp.Tracer("test")
+
+	server, _, err := NewServer(app, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		server.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/cart/add", nil))
+	}
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/spans", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var got []recentSpan
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	// Only the two most recent spans fit, oldest first
+	ended := spans.Ended()
+	want := ended[len(ended)-2:]
+	if len(got) != len(want) {
+		t.Fatalf("got %d spans, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].SpanID != want[i].SpanContext().SpanID().String() || got[i].Name != want[i].Name() {
+			t.Errorf("span %d = %s %s, want %s %s", i, got[i].Name, got[i].SpanID, want[i].Name(), want[i].SpanContext().SpanID())
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// validateEndpoint checks that endpoint is a bare host:port, which is what the
+// exporters expect over either OTLP protocol. Misconfigured endpoints otherwise
+// only surface as cryptic failures at export time.
+func validateEndpoint(endpoint string) error {
+	if endpoint == "" {
+		return fmt.Errorf("collector endpoint is empty: expected host:port, e.g. %q", defaultEndpoint(otlpProtocol))
+	}
+	if scheme, _, found := strings.Cut(endpoint, "://"); found {
+		return fmt.Errorf("collector endpoint %q must not include a scheme (%q): OTLP over %s expects host:port, e.g. %q", endpoint, scheme, otlpProtocol, defaultEndpoint(otlpProtocol))
+	}
+	if i := strings.Index(endpoint, "/"); i >= 0 {
+		return fmt.Errorf("collector endpoint %q must not include a path (%q): OTLP over %s expects host:port", endpoint, endpoint[i:], otlpProtocol)
+	}
+
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return fmt.Errorf("collector endpoint %q is not in host:port form: %w", endpoint, err)
+	}
+	if host == "" {
+		return fmt.Errorf("collector endpoint %q is missing a host", endpoint)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("collector endpoint %q has invalid port %q: expected a number between 1 and 65535", endpoint, port)
+	}
+
+	return nil
+}
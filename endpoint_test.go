@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		wantErr  string
+	}{
+		{endpoint: "localhost:4317"},
+		{endpoint: "[::1]:4317"},
+		{endpoint: "", wantErr: "is empty"},
+		{endpoint: "http://localhost:4317", wantErr: `must not include a scheme ("http")`},
+		{endpoint: "localhost:4317/v1/traces", wantErr: `must not include a path ("/v1/traces")`},
+		{endpoint: "localhost", wantErr: "is not in host:port form"},
+		{endpoint: ":4317", wantErr: "is missing a host"},
+		{endpoint: "localhost:otlp", wantErr: `has invalid port "otlp"`},
+		{endpoint: "localhost:70000", wantErr: `has invalid port "70000"`},
+	}
+	for _, tt := range tests {
+		err := validateEndpoint(tt.endpoint)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("validateEndpoint(%q) = %v, want nil", tt.endpoint, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("validateEndpoint(%q) = %v, want an error containing %q", tt.endpoint, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateEndpointNamesProtocol(t *testing.T) {
+	defer func(orig string) { otlpProtocol = orig }(otlpProtocol)
+
+	for _, protocol := range []string{protocolGRPC, protocolHTTPProtobuf} {
+		otlpProtocol = protocol
+		err := validateEndpoint("http://localhost:4318")
+		if err == nil || !strings.Contains(err.Error(), "OTLP over "+protocol) {
+			t.Errorf("with protocol %s, validateEndpoint = %v, want an error naming the protocol", protocol, err)
+		}
+	}
+}
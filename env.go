@@ -0,0 +1,147 @@
+package main
+
+import (
+	"log"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envString reads a string from the environment, falling back to the given
+// default when the variable is unset or empty.
+func envString(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// envDuration reads a duration such as "5s" from the environment, falling
+// back to the given default when the variable is unset or unparseable.
+func envDuration(key string, fallback time.Duration) time.Duration {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("invalid %s %q, using %s: %v", key, value, fallback, err)
+		return fallback
+	}
+
+	return d
+}
+
+// envMillisDuration is envDuration that also accepts a bare integer as a
+// number of milliseconds, which is how the OpenTelemetry spec defines
+// durations such as OTEL_METRIC_EXPORT_INTERVAL.
+func envMillisDuration(key string, fallback time.Duration) time.Duration {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+
+	if ms, err := strconv.Atoi(value); err == nil {
+		return time.Duration(ms) * time.Millisecond
+	}
+	return envDuration(key, fallback)
+}
+
+// envInt reads an integer from the environment, falling back to the given
+// default when the variable is unset or unparseable.
+func envInt(key string, fallback int) int {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("invalid %s %q, using %d: %v", key, value, fallback, err)
+		return fallback
+	}
+
+	return n
+}
+
+// envBool reads a boolean such as "true" or "0" from the environment, falling
+// back to the given default when the variable is unset or unparseable.
+func envBool(key string, fallback bool) bool {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("invalid %s %q, using %t: %v", key, value, fallback, err)
+		return fallback
+	}
+
+	return b
+}
+
+// otlpTimeout resolves the OTLP exporter timeout for a signal ("TRACES",
+// "METRICS" or "LOGS") from OTEL_EXPORTER_OTLP_<SIGNAL>_TIMEOUT, then
+// OTEL_EXPORTER_OTLP_TIMEOUT. Per the spec, values are in milliseconds. It
+// returns false when neither is set, leaving the exporter default (10s).
+func otlpTimeout(signal string) (time.Duration, bool) {
+	for _, key := range []string{"OTEL_EXPORTER_OTLP_" + signal + "_TIMEOUT", "OTEL_EXPORTER_OTLP_TIMEOUT"} {
+		if ms := envInt(key, 0); ms > 0 {
+			return time.Duration(ms) * time.Millisecond, true
+		}
+	}
+	return 0, false
+}
+
+// envFloat reads a floating point number from the environment, falling back
+// to the given default when the variable is unset or unparseable.
+func envFloat(key string, fallback float64) float64 {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("invalid %s %q, using %g: %v", key, value, fallback, err)
+		return fallback
+	}
+
+	return f
+}
+
+// otlpHeaders parses OTEL_EXPORTER_OTLP_HEADERS, comma-separated key=value
+// pairs sent with every export, e.g. an API key for a vendor backend. Per the
+// spec, values may be percent-encoded. Malformed pairs are skipped.
+func otlpHeaders() map[string]string {
+	headers := map[string]string{}
+	for _, pair := range splitList(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")) {
+		key, value, ok := strings.Cut(pair, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || key == "" {
+			// The entry isn't logged, as it may well be a credential
+			log.Printf("invalid OTEL_EXPORTER_OTLP_HEADERS entry, skipping")
+			continue
+		}
+		if decoded, err := url.PathUnescape(value); err == nil {
+			value = decoded
+		}
+		headers[key] = value
+	}
+	return headers
+}
+
+// redactedHeaders lists header names with their values hidden, for logging.
+func redactedHeaders(headers map[string]string) string {
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key+"=<redacted>")
+	}
+	slices.Sort(keys)
+	return strings.Join(keys, ",")
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"maps"
+	"testing"
+	"time"
+)
+
+func TestEnvMillisDuration(t *testing.T) {
+	const key = "TEST_MILLIS_DURATION"
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "", want: time.Minute},
+		{value: "30000", want: 30 * time.Second},
+		{value: "30s", want: 30 * time.Second},
+		{value: "1m30s", want: 90 * time.Second},
+		{value: "soon", want: time.Minute},
+	}
+	for _, tt := range tests {
+		t.Setenv(key, tt.value)
+		if got := envMillisDuration(key, time.Minute); got != tt.want {
+			t.Errorf("envMillisDuration with %q = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestOTLPHeaders(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "api-key=secret%3D%3D, x-team = checkout,malformed,=no-key")
+
+	got := otlpHeaders()
+	want := map[string]string{
+		// Values are percent-decoded
+		"api-key": "secret==",
+		"x-team":  "checkout",
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("otlpHeaders = %v, want %v", got, want)
+	}
+	if redacted := redactedHeaders(got); redacted != "api-key=<redacted>,x-team=<redacted>" {
+		t.Errorf("redactedHeaders = %q, want the names only", redacted)
+	}
+}
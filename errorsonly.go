@@ -0,0 +1,167 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// exportErrorsOnly keeps only traces that contain an error, as a simple local
+// form of tail sampling.
+var exportErrorsOnly = envBool("EXPORT_ERRORS_ONLY", false)
+
+// Traces whose spans never all end, e.g. because a goroutine leaked a span,
+// would otherwise be held forever. Beyond errorsOnlyMaxTraces pending traces,
+// or once a trace has been pending for errorsOnlyMaxAge, the oldest trace is
+// evicted and its spans dropped.
+var (
+	errorsOnlyMaxTraces = envInt("EXPORT_ERRORS_ONLY_MAX_TRACES", 1000)
+	errorsOnlyMaxAge    = envDuration("EXPORT_ERRORS_ONLY_MAX_AGE", time.Minute)
+)
+
+// errorsOnlyProcessor holds back ended spans until every span of their trace
+// started in this process has ended. It then passes the whole trace on to the
+// wrapped processor if any of its spans has an error status, and drops it
+// otherwise.
+type errorsOnlyProcessor struct {
+	sdktrace.SpanProcessor
+
+	maxTraces int
+	maxAge    time.Duration
+	// evicted, if not nil, counts the traces evicted before they completed
+	evicted metric.Int64Counter
+
+	mu     sync.Mutex
+	traces map[trace.TraceID]*pendingTrace
+	// order holds the trace IDs of traces, oldest first
+	order *list.List
+}
+
+// pendingTrace is a trace with spans that haven't ended yet.
+type pendingTrace struct {
+	started time.Time
+	element *list.Element
+	open    int
+	ended   []sdktrace.ReadOnlySpan
+}
+
+func newErrorsOnlyProcessor(next sdktrace.SpanProcessor, maxTraces int, maxAge time.Duration, evicted metric.Int64Counter) *errorsOnlyProcessor {
+	return &errorsOnlyProcessor{
+		SpanProcessor: next,
+		maxTraces:     maxTraces,
+		maxAge:        maxAge,
+		evicted:       evicted,
+		traces:        make(map[trace.TraceID]*pendingTrace),
+		order:         list.New(),
+	}
+}
+
+func (p *errorsOnlyProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	traceID := s.SpanContext().TraceID()
+	now := time.Now()
+
+	p.mu.Lock()
+	evicted := p.evictLocked(now)
+	t, ok := p.traces[traceID]
+	if !ok {
+		if p.maxTraces > 0 && len(p.traces) >= p.maxTraces {
+			p.removeLocked(p.order.Front().Value.(trace.TraceID))
+			evicted++
+		}
+		t = &pendingTrace{started: now, element: p.order.PushBack(traceID)}
+		p.traces[traceID] = t
+	}
+	t.open++
+	p.mu.Unlock()
+
+	p.recordEvicted(evicted)
+	p.SpanProcessor.OnStart(parent, s)
+}
+
+func (p *errorsOnlyProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	traceID := s.SpanContext().TraceID()
+
+	p.mu.Lock()
+	t, ok := p.traces[traceID]
+	if !ok {
+		// Started before this processor saw it, or its trace was evicted;
+		// judge the span on its own.
+		t = &pendingTrace{open: 1}
+	}
+	t.ended = append(t.ended, s)
+	t.open--
+	if t.open > 0 {
+		p.mu.Unlock()
+		return
+	}
+	if ok {
+		p.removeLocked(traceID)
+	}
+	p.mu.Unlock()
+
+	if !hasErrorSpan(t.ended) {
+		return
+	}
+	for _, span := range t.ended {
+		p.SpanProcessor.OnEnd(span)
+	}
+}
+
+// Shutdown drops traces that are still in progress; nothing has marked them as
+// worth keeping yet.
+func (p *errorsOnlyProcessor) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	clear(p.traces)
+	p.order.Init()
+	p.mu.Unlock()
+
+	return p.SpanProcessor.Shutdown(ctx)
+}
+
+// evictLocked removes the traces that have been pending longer than maxAge
+// and returns how many it removed. p.mu must be held.
+func (p *errorsOnlyProcessor) evictLocked(now time.Time) int64 {
+	if p.maxAge <= 0 {
+		return 0
+	}
+
+	var evicted int64
+	for e := p.order.Front(); e != nil; e = p.order.Front() {
+		traceID := e.Value.(trace.TraceID)
+		if now.Sub(p.traces[traceID].started) < p.maxAge {
+			break
+		}
+		p.removeLocked(traceID)
+		evicted++
+	}
+	return evicted
+}
+
+// removeLocked forgets the pending trace with traceID. p.mu must be held.
+func (p *errorsOnlyProcessor) removeLocked(traceID trace.TraceID) {
+	p.order.Remove(p.traces[traceID].element)
+	delete(p.traces, traceID)
+}
+
+// recordEvicted counts pending traces dropped before they completed.
+func (p *errorsOnlyProcessor) recordEvicted(n int64) {
+	if n > 0 && p.evicted != nil {
+		p.evicted.Add(context.Background(), n)
+	}
+}
+
+// hasErrorSpan reports whether any of spans has an error status.
+func hasErrorSpan(spans []sdktrace.ReadOnlySpan) bool {
+	for _, s := range spans {
+		if s.Status().Code == codes.Error {
+			return true
+		}
+	}
+	return false
+}
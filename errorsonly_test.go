@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestErrorsOnlyProcessor(t *testing.T) {
+	ctx := context.Background()
+	spans := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(newErrorsOnlyProcessor(spans, 0, 0, nil)))
+	defer func() { _ = tp.Shutdown(ctx) }()
+	tracer := tp.Tracer("test")
+
+	for _, failed := range []bool{false, true} {
+		ctx, request := tracer.Start(ctx, "request")
+		_, phase := tracer.Start(ctx, "phase")
+		if failed {
+			phase.SetStatus(codes.Error, "failed")
+		}
+		phase.End()
+		request.End()
+	}
+
+	// Only the failed request's trace is kept, all of it
+	ended := spans.Ended()
+	if len(ended) != 2 {
+		t.Fatalf("got %d spans, want the 2 of the failed request", len(ended))
+	}
+	if ended[0].SpanContext().TraceID() != ended[1].SpanContext().TraceID() {
+		t.Error("the exported spans belong to different traces")
+	}
+}
+
+func TestErrorsOnlyProcessorEviction(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxTraces int
+		maxAge    time.Duration
+	}{
+		{name: "max traces", maxTraces: 2},
+		{name: "max age", maxAge: time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _, reader := newTestApp(t, config{})
+			ctx := context.Background()
+			processor := newErrorsOnlyProcessor(tracetest.NewSpanRecorder(), tt.maxTraces, tt.maxAge, app.evictedTracesCounter)
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(processor))
+			defer func() { _ = tp.Shutdown(ctx) }()
+
+			// Traces whose root span never ends
+			_, oldest := tp.Tracer("test").Start(ctx, "oldest")
+			time.Sleep(5 * time.Millisecond)
+			tp.Tracer("test").Start(ctx, "second")
+			tp.Tracer("test").Start(ctx, "third")
+
+			processor.mu.Lock()
+			_, pending := processor.traces[oldest.SpanContext().TraceID()]
+			processor.mu.Unlock()
+			if pending {
+				t.Error("the oldest trace is still pending")
+			}
+			if got := collectInt64Sum(t, reader, "otel.span.errors_only.evicted"); got < 1 {
+				t.Errorf("otel.span.errors_only.evicted = %d, want at least 1", got)
+			}
+		})
+	}
+}
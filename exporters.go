@@ -0,0 +1,394 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutlog"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// stdoutExport prints telemetry to stdout instead of exporting it to a
+// collector, so the app runs without any external dependencies.
+var stdoutExport = exporterKind() == "stdout"
+
+// exporterKind returns OTEL_EXPORTER, "otlp" or "stdout".
+func exporterKind() string {
+	kind := envString("OTEL_EXPORTER", "otlp")
+	if kind != "otlp" && kind != "stdout" {
+		log.Printf("invalid OTEL_EXPORTER %q, using otlp", kind)
+		return "otlp"
+	}
+	return kind
+}
+
+// metricExporters returns an exporter per target, or a stdout exporter when
+// stdoutExport is set.
+func metricExporters(ctx context.Context, targets []otlpTarget) ([]sdkmetric.Exporter, error) {
+	if stdoutExport {
+		exporter, err := stdoutmetric.New(stdoutmetric.WithPrettyPrint())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create stdout metrics exporter: %w", err)
+		}
+		return []sdkmetric.Exporter{&trackingMetricExporter{Exporter: exporter}}, nil
+	}
+
+	exporters := make([]sdkmetric.Exporter, 0, len(targets))
+	for _, target := range targets {
+		exporter, err := newMetricExporter(ctx, target)
+		if err != nil {
+			return nil, err
+		}
+		exporters = append(exporters, exporter)
+	}
+	return exporters, nil
+}
+
+// traceExporters returns an exporter per target, or a stdout exporter when
+// stdoutExport is set.
+func traceExporters(ctx context.Context, targets []otlpTarget) ([]sdktrace.SpanExporter, error) {
+	if stdoutExport {
+		exporter, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create stdout traces exporter: %w", err)
+		}
+		return []sdktrace.SpanExporter{exporter}, nil
+	}
+
+	exporters := make([]sdktrace.SpanExporter, 0, len(targets))
+	for _, target := range targets {
+		exporter, err := newTraceExporter(ctx, target)
+		if err != nil {
+			return nil, err
+		}
+		exporters = append(exporters, exporter)
+	}
+	return exporters, nil
+}
+
+// logExporters returns an exporter per target, or a stdout exporter when
+// stdoutExport is set.
+func logExporters(ctx context.Context, targets []otlpTarget) ([]sdklog.Exporter, error) {
+	if stdoutExport {
+		exporter, err := stdoutlog.New(stdoutlog.WithPrettyPrint())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create stdout logs exporter: %w", err)
+		}
+		return []sdklog.Exporter{exporter}, nil
+	}
+
+	exporters := make([]sdklog.Exporter, 0, len(targets))
+	for _, target := range targets {
+		exporter, err := newLogExporter(ctx, target)
+		if err != nil {
+			return nil, err
+		}
+		exporters = append(exporters, exporter)
+	}
+	return exporters, nil
+}
+
+// exportHeaders are sent with every OTLP export, from OTEL_EXPORTER_OTLP_HEADERS.
+var exportHeaders = otlpHeaders()
+
+// retryConfig has the same layout as the RetryConfig of every OTLP exporter
+// package, so that one policy converts to each of them.
+type retryConfig struct {
+	Enabled         bool
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	MaxElapsedTime  time.Duration
+}
+
+// exportRetry retries failed exports with exponential backoff for up to
+// OTEL_EXPORTER_OTLP_RETRY_MAX_ELAPSED_TIME, after which the batch is
+// dropped. 0 disables retries.
+var exportRetry = func() retryConfig {
+	maxElapsed := envDuration("OTEL_EXPORTER_OTLP_RETRY_MAX_ELAPSED_TIME", time.Minute)
+	return retryConfig{
+		Enabled:         maxElapsed > 0,
+		InitialInterval: 5 * time.Second,
+		MaxInterval:     30 * time.Second,
+		MaxElapsedTime:  maxElapsed,
+	}
+}()
+
+// otlpTarget is a collector that telemetry is exported to. conn is only set
+// when exporting over gRPC. Over OTLP/HTTP, tlsConfig is nil when exporting
+// without TLS.
+type otlpTarget struct {
+	endpoint  string
+	conn      *grpc.ClientConn
+	tlsConfig *tls.Config
+}
+
+// OTLP transports selectable with OTEL_EXPORTER_OTLP_PROTOCOL.
+const (
+	protocolGRPC         = "grpc"
+	protocolHTTPProtobuf = "http/protobuf"
+)
+
+// otlpProtocol is the transport used to export to collectors.
+var otlpProtocol = envString("OTEL_EXPORTER_OTLP_PROTOCOL", protocolGRPC)
+
+// defaultEndpoint returns the local collector endpoint for protocol, on the
+// standard OTLP port.
+func defaultEndpoint(protocol string) string {
+	if protocol == protocolHTTPProtobuf {
+		return "localhost:4318"
+	}
+	return "localhost:4317"
+}
+
+// exporterFallback makes targets fall back to OTLP/HTTP when the collector
+// can't be reached over gRPC, e.g. because it only speaks HTTP.
+var exporterFallback = envBool("OTEL_EXPORTER_FALLBACK", false)
+
+// grpcProbeTimeout bounds how long newGrpcTarget waits for a gRPC connection
+// to become ready before falling back to OTLP/HTTP.
+var grpcProbeTimeout = 5 * time.Second
+
+// newOTLPTarget validates endpoint and prepares to export to it over
+// otlpProtocol.
+func newOTLPTarget(ctx context.Context, endpoint string) (otlpTarget, error) {
+	switch otlpProtocol {
+	case protocolGRPC:
+		return newGrpcTarget(ctx, endpoint)
+	case protocolHTTPProtobuf:
+		if err := validateEndpoint(endpoint); err != nil {
+			return otlpTarget{}, err
+		}
+		tlsConfig, err := otlpTLSConfig(endpoint)
+		if err != nil {
+			return otlpTarget{}, err
+		}
+		return otlpTarget{endpoint: endpoint, tlsConfig: tlsConfig}, nil
+	default:
+		return otlpTarget{}, fmt.Errorf("unsupported OTLP protocol %q, want %q or %q", otlpProtocol, protocolGRPC, protocolHTTPProtobuf)
+	}
+}
+
+// newGrpcTarget creates the gRPC connection to endpoint. If exporterFallback
+// is enabled and the collector doesn't answer over gRPC, the target exports
+// over OTLP/HTTP instead.
+func newGrpcTarget(ctx context.Context, endpoint string) (otlpTarget, error) {
+	conn, err := initGrpcConn(endpoint)
+	if err != nil || !exporterFallback {
+		return otlpTarget{endpoint: endpoint, conn: conn}, err
+	}
+
+	if err := probeGrpcConn(ctx, conn, grpcProbeTimeout); err != nil {
+		_ = conn.Close()
+		httpEndpoint := fallbackHTTPEndpoint(endpoint)
+		log.Printf("Failed to connect to the collector over gRPC, falling back to OTLP/HTTP at %s: %v", httpEndpoint, err)
+		tlsConfig, err := otlpTLSConfig(httpEndpoint)
+		if err != nil {
+			return otlpTarget{}, err
+		}
+		return otlpTarget{endpoint: httpEndpoint, tlsConfig: tlsConfig}, nil
+	}
+
+	return otlpTarget{endpoint: endpoint, conn: conn}, nil
+}
+
+// probeGrpcConn connects conn and waits up to timeout for it to become ready.
+// grpc.NewClient doesn't connect by itself, so without probing a collector
+// that can't be reached over gRPC would only show up as failed exports.
+func probeGrpcConn(ctx context.Context, conn *grpc.ClientConn, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn.Connect()
+	for {
+		state := conn.GetState()
+		switch state {
+		case connectivity.Ready:
+			return nil
+		case connectivity.TransientFailure, connectivity.Shutdown:
+			return fmt.Errorf("gRPC connection to %s is in state %s", conn.Target(), state)
+		}
+		if !conn.WaitForStateChange(ctx, state) {
+			return fmt.Errorf("gRPC connection to %s not ready after %s", conn.Target(), timeout)
+		}
+	}
+}
+
+// fallbackHTTPEndpoint returns the OTLP/HTTP endpoint used when falling back
+// from gRPC to endpoint: OTEL_EXPORTER_OTLP_HTTP_ENDPOINT, or the same host on
+// the standard OTLP/HTTP port.
+func fallbackHTTPEndpoint(endpoint string) string {
+	host, _, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		host = "localhost"
+	}
+	return envString("OTEL_EXPORTER_OTLP_HTTP_ENDPOINT", net.JoinHostPort(host, "4318"))
+}
+
+// metricTemporality reports observable up-down counters as deltas, so that
+// api.cart.churn shows the net cart activity of each collection interval, and
+// everything else cumulatively. Each reader computes the deltas from its own
+// previous collection.
+func metricTemporality(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+	if kind == sdkmetric.InstrumentKindObservableUpDownCounter {
+		return metricdata.DeltaTemporality
+	}
+	return sdkmetric.DefaultTemporalitySelector(kind)
+}
+
+// newMetricExporter creates an OTLP metric exporter sending to target.
+func newMetricExporter(ctx context.Context, target otlpTarget) (sdkmetric.Exporter, error) {
+	var metricExporter sdkmetric.Exporter
+	var err error
+	if target.conn != nil {
+		opts := []otlpmetricgrpc.Option{
+			otlpmetricgrpc.WithGRPCConn(target.conn),
+			otlpmetricgrpc.WithTemporalitySelector(metricTemporality),
+		}
+		if timeout, ok := otlpTimeout("METRICS"); ok {
+			log.Printf("Using metrics export timeout of %s", timeout)
+			opts = append(opts, otlpmetricgrpc.WithTimeout(timeout))
+		}
+		if len(exportHeaders) > 0 {
+			opts = append(opts, otlpmetricgrpc.WithHeaders(exportHeaders))
+		}
+		opts = append(opts, otlpmetricgrpc.WithRetry(otlpmetricgrpc.RetryConfig(exportRetry)))
+		metricExporter, err = otlpmetricgrpc.New(ctx, opts...)
+	} else {
+		opts := []otlpmetrichttp.Option{
+			otlpmetrichttp.WithEndpoint(target.endpoint),
+			otlpmetrichttp.WithTemporalitySelector(metricTemporality),
+		}
+		if target.tlsConfig != nil {
+			opts = append(opts, otlpmetrichttp.WithTLSClientConfig(target.tlsConfig))
+		} else {
+			opts = append(opts, otlpmetrichttp.WithInsecure())
+		}
+		if timeout, ok := otlpTimeout("METRICS"); ok {
+			opts = append(opts, otlpmetrichttp.WithTimeout(timeout))
+		}
+		if len(exportHeaders) > 0 {
+			opts = append(opts, otlpmetrichttp.WithHeaders(exportHeaders))
+		}
+		opts = append(opts, otlpmetrichttp.WithRetry(otlpmetrichttp.RetryConfig(exportRetry)))
+		metricExporter, err = otlpmetrichttp.New(ctx, opts...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metrics exporter: %w", err)
+	}
+
+	return &trackingMetricExporter{Exporter: metricExporter}, nil
+}
+
+// newTraceExporter creates an OTLP span exporter sending to target.
+func newTraceExporter(ctx context.Context, target otlpTarget) (sdktrace.SpanExporter, error) {
+	var traceExporter sdktrace.SpanExporter
+	var err error
+	if target.conn != nil {
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithGRPCConn(target.conn)}
+		if timeout, ok := otlpTimeout("TRACES"); ok {
+			log.Printf("Using traces export timeout of %s", timeout)
+			opts = append(opts, otlptracegrpc.WithTimeout(timeout))
+		}
+		if len(exportHeaders) > 0 {
+			opts = append(opts, otlptracegrpc.WithHeaders(exportHeaders))
+		}
+		opts = append(opts, otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig(exportRetry)))
+		traceExporter, err = otlptracegrpc.New(ctx, opts...)
+	} else {
+		opts := []otlptracehttp.Option{
+			otlptracehttp.WithEndpoint(target.endpoint),
+		}
+		if target.tlsConfig != nil {
+			opts = append(opts, otlptracehttp.WithTLSClientConfig(target.tlsConfig))
+		} else {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		if timeout, ok := otlpTimeout("TRACES"); ok {
+			opts = append(opts, otlptracehttp.WithTimeout(timeout))
+		}
+		if len(exportHeaders) > 0 {
+			opts = append(opts, otlptracehttp.WithHeaders(exportHeaders))
+		}
+		opts = append(opts, otlptracehttp.WithRetry(otlptracehttp.RetryConfig(exportRetry)))
+		traceExporter, err = otlptracehttp.New(ctx, opts...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create traces exporter: %w", err)
+	}
+
+	return traceExporter, nil
+}
+
+// newLogExporter creates an OTLP log exporter sending to target. Over gRPC the
+// exporter ignores compression on a connection it didn't dial, and the
+// connection is shared with the other signals, so a compression setting is
+// reported rather than silently dropped.
+func newLogExporter(ctx context.Context, target otlpTarget) (sdklog.Exporter, error) {
+	var logExporter sdklog.Exporter
+	var err error
+	if target.conn != nil {
+		if _, ok := os.LookupEnv("OTEL_EXPORTER_OTLP_LOGS_COMPRESSION"); ok {
+			log.Printf("Ignoring OTEL_EXPORTER_OTLP_LOGS_COMPRESSION=%s: logs exported over gRPC share the collector connection, which is not compressed", logCompression)
+		}
+
+		opts := []otlploggrpc.Option{otlploggrpc.WithGRPCConn(target.conn)}
+		if timeout, ok := otlpTimeout("LOGS"); ok {
+			log.Printf("Using logs export timeout of %s", timeout)
+			opts = append(opts, otlploggrpc.WithTimeout(timeout))
+		}
+		if len(exportHeaders) > 0 {
+			opts = append(opts, otlploggrpc.WithHeaders(exportHeaders))
+		}
+		opts = append(opts, otlploggrpc.WithRetry(otlploggrpc.RetryConfig(exportRetry)))
+		logExporter, err = otlploggrpc.New(ctx, opts...)
+	} else {
+		opts := []otlploghttp.Option{
+			otlploghttp.WithEndpoint(target.endpoint),
+		}
+		if target.tlsConfig != nil {
+			opts = append(opts, otlploghttp.WithTLSClientConfig(target.tlsConfig))
+		} else {
+			opts = append(opts, otlploghttp.WithInsecure())
+		}
+		if timeout, ok := otlpTimeout("LOGS"); ok {
+			opts = append(opts, otlploghttp.WithTimeout(timeout))
+		}
+		if len(exportHeaders) > 0 {
+			opts = append(opts, otlploghttp.WithHeaders(exportHeaders))
+		}
+		opts = append(opts, otlploghttp.WithRetry(otlploghttp.RetryConfig(exportRetry)))
+		switch logCompression {
+		case "gzip":
+			opts = append(opts, otlploghttp.WithCompression(otlploghttp.GzipCompression))
+		case "none":
+			opts = append(opts, otlploghttp.WithCompression(otlploghttp.NoCompression))
+		default:
+			log.Printf("invalid OTEL_EXPORTER_OTLP_LOGS_COMPRESSION %q, using gzip", logCompression)
+			opts = append(opts, otlploghttp.WithCompression(otlploghttp.GzipCompression))
+		}
+		logExporter, err = otlploghttp.New(ctx, opts...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create logs exporter: %w", err)
+	}
+
+	return logExporter, nil
+}
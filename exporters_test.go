@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+)
+
+func TestGRPCFallbackToHTTP(t *testing.T) {
+	defer func(orig bool) { exporterFallback = orig }(exporterFallback)
+	ctx := context.Background()
+
+	// A collector that only speaks OTLP/HTTP
+	collector := httptest.NewServer(http.NotFoundHandler())
+	defer collector.Close()
+	endpoint := strings.TrimPrefix(collector.URL, "http://")
+	t.Setenv("OTEL_EXPORTER_OTLP_HTTP_ENDPOINT", endpoint)
+
+	// Without fallback the connection isn't probed, so the target stays on gRPC
+	exporterFallback = false
+	target, err := newOTLPTarget(ctx, endpoint)
+	if err != nil {
+		t.Fatalf("newOTLPTarget without fallback: %v", err)
+	}
+	if target.conn == nil {
+		t.Fatal("target without fallback has no gRPC connection")
+	}
+	_ = target.conn.Close()
+
+	exporterFallback = true
+	target, err = newOTLPTarget(ctx, endpoint)
+	if err != nil {
+		t.Fatalf("newOTLPTarget with fallback: %v", err)
+	}
+	if target.conn != nil {
+		t.Fatal("fallback target has a gRPC connection")
+	}
+	if target.endpoint != endpoint {
+		t.Errorf("fallback endpoint = %q, want %q", target.endpoint, endpoint)
+	}
+
+	metricExporter, err := newMetricExporter(ctx, target)
+	if err != nil {
+		t.Fatalf("newMetricExporter: %v", err)
+	}
+	defer func() { _ = metricExporter.Shutdown(ctx) }()
+
+	traceExporter, err := newTraceExporter(ctx, target)
+	if err != nil {
+		t.Fatalf("newTraceExporter: %v", err)
+	}
+	defer func() { _ = traceExporter.Shutdown(ctx) }()
+
+	logExporter, err := newLogExporter(ctx, target)
+	if err != nil {
+		t.Fatalf("newLogExporter: %v", err)
+	}
+	defer func() { _ = logExporter.Shutdown(ctx) }()
+}
+
+func TestNoFallbackFromWorkingGRPC(t *testing.T) {
+	defer func(orig bool) { exporterFallback = orig }(exporterFallback)
+	exporterFallback = true
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+
+	target, err := newOTLPTarget(context.Background(), lis.Addr().String())
+	if err != nil {
+		t.Fatalf("newOTLPTarget: %v", err)
+	}
+	if target.conn == nil {
+		t.Fatal("fell back to OTLP/HTTP although the collector speaks gRPC")
+	}
+	_ = target.conn.Close()
+}
+
+func TestHTTPProtobufProtocol(t *testing.T) {
+	defer func(orig string) { otlpProtocol = orig }(otlpProtocol)
+	otlpProtocol = protocolHTTPProtobuf
+	ctx := context.Background()
+
+	paths := make(chan string, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths <- r.URL.Path
+	}))
+	defer collector.Close()
+	endpoint := strings.TrimPrefix(collector.URL, "http://")
+
+	target, err := newOTLPTarget(ctx, endpoint)
+	if err != nil {
+		t.Fatalf("newOTLPTarget: %v", err)
+	}
+	if target.conn != nil {
+		t.Fatal("OTLP/HTTP target has a gRPC connection")
+	}
+
+	exporter, err := newTraceExporter(ctx, target)
+	if err != nil {
+		t.Fatalf("newTraceExporter: %v", err)
+	}
+	defer func() { _ = exporter.Shutdown(ctx) }()
+	if err := exporter.ExportSpans(ctx, tracetest.SpanStubs{{Name: "span"}}.Snapshots()); err != nil {
+		t.Fatalf("ExportSpans: %v", err)
+	}
+	if path := <-paths; path != "/v1/traces" {
+		t.Errorf("exported to %s, want /v1/traces", path)
+	}
+}
+
+func TestExportHeaders(t *testing.T) {
+	defer func(orig string) { otlpProtocol = orig }(otlpProtocol)
+	otlpProtocol = protocolHTTPProtobuf
+	defer func(orig map[string]string) { exportHeaders = orig }(exportHeaders)
+	exportHeaders = map[string]string{"api-key": "secret"}
+	ctx := context.Background()
+
+	keys := make(chan string, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys <- r.Header.Get("api-key")
+	}))
+	defer collector.Close()
+
+	target, err := newOTLPTarget(ctx, strings.TrimPrefix(collector.URL, "http://"))
+	if err != nil {
+		t.Fatalf("newOTLPTarget: %v", err)
+	}
+	exporter, err := newTraceExporter(ctx, target)
+	if err != nil {
+		t.Fatalf("newTraceExporter: %v", err)
+	}
+	defer func() { _ = exporter.Shutdown(ctx) }()
+	if err := exporter.ExportSpans(ctx, tracetest.SpanStubs{{Name: "span"}}.Snapshots()); err != nil {
+		t.Fatalf("ExportSpans: %v", err)
+	}
+	if key := <-keys; key != "secret" {
+		t.Errorf("api-key header = %q, want %q", key, "secret")
+	}
+}
+
+func TestExportRetry(t *testing.T) {
+	defer func(orig string) { otlpProtocol = orig }(otlpProtocol)
+	otlpProtocol = protocolHTTPProtobuf
+	defer func(orig retryConfig) { exportRetry = orig }(exportRetry)
+	ctx := context.Background()
+
+	// The collector is unavailable for the first request only
+	var requests atomic.Int32
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer collector.Close()
+	target, err := newOTLPTarget(ctx, strings.TrimPrefix(collector.URL, "http://"))
+	if err != nil {
+		t.Fatalf("newOTLPTarget: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		retry   retryConfig
+		wantErr bool
+	}{
+		{"enabled", retryConfig{Enabled: true, InitialInterval: time.Millisecond, MaxInterval: time.Millisecond, MaxElapsedTime: time.Second}, false},
+		{"disabled", retryConfig{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests.Store(0)
+			exportRetry = tt.retry
+			exporter, err := newTraceExporter(ctx, target)
+			if err != nil {
+				t.Fatalf("newTraceExporter: %v", err)
+			}
+			defer func() { _ = exporter.Shutdown(ctx) }()
+
+			err = exporter.ExportSpans(ctx, tracetest.SpanStubs{{Name: "span"}}.Snapshots())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ExportSpans error = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUnsupportedProtocol(t *testing.T) {
+	defer func(orig string) { otlpProtocol = orig }(otlpProtocol)
+	otlpProtocol = "http/json"
+
+	if _, err := newOTLPTarget(context.Background(), "localhost:4318"); err == nil {
+		t.Error("newOTLPTarget accepted an unsupported protocol")
+	}
+}
+
+func TestStdoutExport(t *testing.T) {
+	defer func(orig bool) { stdoutExport = orig }(stdoutExport)
+	stdoutExport = true
+	ctx := context.Background()
+
+	// The targets are ignored, so nothing is dialed
+	targets := []otlpTarget{{endpoint: "collector.invalid:4317"}}
+
+	metricExporters, err := metricExporters(ctx, targets)
+	if err != nil || len(metricExporters) != 1 {
+		t.Fatalf("metricExporters = %d exporters, %v; want 1", len(metricExporters), err)
+	}
+	traceExporters, err := traceExporters(ctx, targets)
+	if err != nil || len(traceExporters) != 1 {
+		t.Fatalf("traceExporters = %d exporters, %v; want 1", len(traceExporters), err)
+	}
+	logExporters, err := logExporters(ctx, targets)
+	if err != nil || len(logExporters) != 1 {
+		t.Fatalf("logExporters = %d exporters, %v; want 1", len(logExporters), err)
+	}
+	if _, ok := traceExporters[0].(*stdouttrace.Exporter); !ok {
+		t.Errorf("trace exporter is a %T, want a stdout exporter", traceExporters[0])
+	}
+}
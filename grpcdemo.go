@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// greetMethod is the full name of the simulated gRPC method.
+const greetMethod = "/demo.Greeter/SayHello"
+
+// metadataCarrier adapts gRPC metadata to a propagation.TextMapCarrier, which
+// is how trace context travels over gRPC instead of HTTP headers.
+type metadataCarrier metadata.MD
+
+var _ propagation.TextMapCarrier = metadataCarrier{}
+
+func (c metadataCarrier) Get(key string) string {
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+var (
+	_ grpc.UnaryClientInterceptor = (*App)(nil).tracingUnaryClientInterceptor
+	_ grpc.UnaryInvoker           = (*App)(nil).inProcessInvoker
+)
+
+// tracingUnaryClientInterceptor wraps a unary gRPC call in a client span and
+// injects the trace context into the outgoing metadata.
+func (a *App) tracingUnaryClientInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	ctx, span := a.tracer.Start(ctx, method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "grpc"),
+			attribute.String("rpc.method", method),
+		),
+	)
+	defer span.End()
+
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	otel.GetTextMapPropagator().Inject(ctx, metadataCarrier(md))
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if err != nil {
+		recordError(span, err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// inProcessInvoker stands in for a remote gRPC server. It reads the trace
+// context from the metadata as a real server would after it crossed the wire,
+// and handles the call in a server span continuing the caller's trace.
+func (a *App) inProcessInvoker(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	serverCtx := otel.GetTextMapPropagator().Extract(context.Background(), metadataCarrier(md))
+
+	_, span := a.tracer.Start(serverCtx, method, trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+
+	*reply.(*string) = "Hello, " + req.(string) + "!"
+	return nil
+}
+
+// grpcHandler demonstrates trace propagation over a (simulated) gRPC call.
+func (a *App) grpcHandler(w http.ResponseWriter, r *http.Request) {
+	var reply string
+	if err := a.tracingUnaryClientInterceptor(r.Context(), greetMethod, "World", &reply, nil, a.inProcessInvoker); err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(reply))
+}
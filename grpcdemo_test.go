@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestGRPCMetadataCarriesTraceparent(t *testing.T) {
+	usePropagator(t)
+	app, spans, _ := newTestApp(t, config{})
+
+	var injected metadata.MD
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		injected, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	}
+	var reply string
+	if err := app.tracingUnaryClientInterceptor(context.Background(), greetMethod, "World", &reply, nil, invoker); err != nil {
+		t.Fatal(err)
+	}
+
+	client := endedSpan(t, spans, greetMethod)
+	if client.SpanKind() != trace.SpanKindClient {
+		t.Errorf("span kind = %s, want %s", client.SpanKind(), trace.SpanKindClient)
+	}
+	traceparents := injected.Get("traceparent")
+	if len(traceparents) != 1 {
+		t.Fatalf("metadata %v has no traceparent", injected)
+	}
+	if got := parseTraceparent(traceparents[0]); !got.Equal(client.SpanContext().WithRemote(true)) {
+		t.Errorf("traceparent %s does not identify the client span %v", traceparents[0], client.SpanContext())
+	}
+}
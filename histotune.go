@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"log"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/embedded"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+var (
+	// latencyAutoTune recomputes the latency histogram's bucket boundaries
+	// from the first latencyAutoTuneWarmup observed latencies.
+	latencyAutoTune        = envBool("LATENCY_AUTOTUNE", false)
+	latencyAutoTuneWarmup  = envInt("LATENCY_AUTOTUNE_WARMUP", 1000)
+	latencyAutoTuneBuckets = envInt("LATENCY_AUTOTUNE_BUCKETS", 10)
+)
+
+// bucketTuner is a histogram that lives on a meter provider of its own. Views
+// only apply when an instrument is created, so once warmup is over the tuner
+// builds a new provider whose view uses boundaries fitted to the warmup
+// samples, switches recording over to it and shuts down the old one.
+type bucketTuner struct {
+	embedded.Float64Histogram
+
+	warmup       int
+	buckets      int
+	scope        string
+	newProvider  func(extra ...sdkmetric.View) (*sdkmetric.MeterProvider, error)
+	newHistogram func(metric.Meter) (metric.Float64Histogram, error)
+
+	current atomic.Pointer[metric.Float64Histogram]
+
+	mu       sync.Mutex
+	provider *sdkmetric.MeterProvider
+	samples  []float64
+	tuned    bool
+	closed   bool
+}
+
+func newBucketTuner(warmup, buckets int, newProvider func(extra ...sdkmetric.View) (*sdkmetric.MeterProvider, error)) *bucketTuner {
+	return &bucketTuner{warmup: warmup, buckets: buckets, newProvider: newProvider}
+}
+
+// start creates the histogram with the default buckets, using a meter named
+// scope, and returns the tuner, which records into it until the tuned
+// histogram replaces it.
+func (t *bucketTuner) start(scope string, newHistogram func(metric.Meter) (metric.Float64Histogram, error)) (metric.Float64Histogram, error) {
+	provider, err := t.newProvider()
+	if err != nil {
+		return nil, err
+	}
+	h, err := newHistogram(provider.Meter(scope))
+	if err != nil {
+		_ = provider.Shutdown(context.Background())
+		return nil, err
+	}
+
+	t.scope = scope
+	t.newHistogram = newHistogram
+	t.provider = provider
+	t.current.Store(&h)
+	return t, nil
+}
+
+func (t *bucketTuner) Record(ctx context.Context, value float64, options ...metric.RecordOption) {
+	(*t.current.Load()).Record(ctx, value, options...)
+	t.observe(value)
+}
+
+// observe collects warmup samples and starts retuning once there are enough.
+func (t *bucketTuner) observe(value float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.tuned {
+		return
+	}
+	t.samples = append(t.samples, value)
+	if len(t.samples) < t.warmup {
+		return
+	}
+
+	t.tuned = true
+	boundaries := quantileBoundaries(t.samples, t.buckets)
+	t.samples = nil
+	go t.retune(boundaries)
+}
+
+// retune moves recording to a new provider using the given boundaries.
+func (t *bucketTuner) retune(boundaries []float64) {
+	provider, err := t.newProvider(sdkmetric.NewView(
+		sdkmetric.Instrument{Kind: sdkmetric.InstrumentKindHistogram},
+		sdkmetric.Stream{Aggregation: sdkmetric.AggregationExplicitBucketHistogram{Boundaries: boundaries}},
+	))
+	if err != nil {
+		log.Printf("failed to retune latency buckets: %v", err)
+		return
+	}
+	h, err := t.newHistogram(provider.Meter(t.scope))
+	if err != nil {
+		log.Printf("failed to retune latency buckets: %v", err)
+		_ = provider.Shutdown(context.Background())
+		return
+	}
+
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		_ = provider.Shutdown(context.Background())
+		return
+	}
+	old := t.provider
+	t.provider = provider
+	t.current.Store(&h)
+	t.mu.Unlock()
+
+	log.Printf("retuned latency buckets to %v", boundaries)
+
+	// Shutting down flushes what the old provider recorded during warmup.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := old.Shutdown(ctx); err != nil {
+		log.Printf("failed to shutdown previous latency MeterProvider: %v", err)
+	}
+}
+
+// flush flushes the provider currently backing the histogram.
+func (t *bucketTuner) flush(ctx context.Context) error {
+	t.mu.Lock()
+	provider := t.provider
+	t.mu.Unlock()
+
+	if provider == nil {
+		return nil
+	}
+	return provider.ForceFlush(ctx)
+}
+
+// shutdown shuts down the provider currently backing the histogram.
+func (t *bucketTuner) shutdown(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.closed = true
+	if t.provider == nil {
+		return nil
+	}
+	return t.provider.Shutdown(ctx)
+}
+
+// quantileBoundaries returns up to buckets-1 increasing boundaries at evenly
+// spaced quantiles of samples, so that each bucket receives a similar share of
+// the observed values.
+func quantileBoundaries(samples []float64, buckets int) []float64 {
+	sorted := slices.Clone(samples)
+	slices.Sort(sorted)
+
+	var boundaries []float64
+	for i := 1; i < buckets; i++ {
+		b := sorted[i*len(sorted)/buckets]
+		if len(boundaries) == 0 || b > boundaries[len(boundaries)-1] {
+			boundaries = append(boundaries, b)
+		}
+	}
+	return boundaries
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestQuantileBoundaries(t *testing.T) {
+	samples := make([]float64, 100)
+	for i := range samples {
+		samples[i] = float64(i + 1)
+	}
+	rand.Shuffle(len(samples), func(i, j int) { samples[i], samples[j] = samples[j], samples[i] })
+
+	if got, want := quantileBoundaries(samples, 4), []float64{26, 51, 76}; !slices.Equal(got, want) {
+		t.Errorf("quantileBoundaries = %v, want %v", got, want)
+	}
+	// Repeated values don't produce duplicate boundaries
+	if got, want := quantileBoundaries([]float64{1, 1, 1, 1, 2}, 5), []float64{1, 2}; !slices.Equal(got, want) {
+		t.Errorf("quantileBoundaries with repeated values = %v, want %v", got, want)
+	}
+}
+
+func TestBucketTunerBracketsMedian(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		readers []*sdkmetric.ManualReader
+	)
+	newProvider := func(extra ...sdkmetric.View) (*sdkmetric.MeterProvider, error) {
+		reader := sdkmetric.NewManualReader()
+		mu.Lock()
+		readers = append(readers, reader)
+		mu.Unlock()
+		return sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader), sdkmetric.WithView(extra...)), nil
+	}
+
+	tuner := newBucketTuner(100, 4, newProvider)
+	histogram, err := tuner.start("test", func(m metric.Meter) (metric.Float64Histogram, error) {
+		return m.Float64Histogram("latency")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	defer func() { _ = tuner.shutdown(ctx) }()
+	warmupProvider := tuner.provider
+
+	// Warmup latencies between 10ms and 50ms, with a median of 30ms
+	for i := range 100 {
+		histogram.Record(ctx, 0.010+float64(i)*0.0004)
+	}
+	const median = 0.030
+
+	// Retuning happens in the background
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		tuner.mu.Lock()
+		switched := tuner.provider != warmupProvider
+		tuner.mu.Unlock()
+		if switched {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the histogram was not retuned after warmup")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	histogram.Record(ctx, median)
+
+	mu.Lock()
+	reader := readers[len(readers)-1]
+	mu.Unlock()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	bounds := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Histogram[float64]).DataPoints[0].Bounds
+	if len(bounds) == 0 || median < bounds[0] || median > bounds[len(bounds)-1] {
+		t.Errorf("tuned boundaries %v do not bracket the median %v", bounds, median)
+	}
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+// registerInstruments creates every instrument up front, so that none is
+// created lazily while serving the first requests.
+func (a *App) registerInstruments() error {
+	meter := a.meter
+	var err error
+
+	// Count
+	a.requestCounter, err = meter.Int64Counter(
+		"api.request.counter",
+		metric.WithDescription("Number of API calls."),
+		metric.WithUnit("{call}"),
+	)
+	if err != nil {
+		return err
+	}
+
+	a.canceledCounter, err = meter.Int64Counter(
+		"api.request.canceled",
+		metric.WithDescription("Number of API calls canceled before a response was written."),
+		metric.WithUnit("{call}"),
+	)
+	if err != nil {
+		return err
+	}
+
+	a.errorCounter, err = meter.Int64Counter(
+		"api.request.error_counter",
+		metric.WithDescription("Number of erroneous API calls."),
+		metric.WithUnit("{call}"),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Histogram
+	newLatencyHistogram := func(meter metric.Meter) (metric.Float64Histogram, error) {
+		return meter.Float64Histogram(
+			"api.request.latency_seconds",
+			metric.WithDescription("Records the latency of requests in seconds"),
+			metric.WithUnit("{s}"),
+		)
+	}
+	if a.latencyTuner != nil {
+		a.latencyHistogram, err = a.latencyTuner.start(a.cfg.serviceName, newLatencyHistogram)
+	} else {
+		a.latencyHistogram, err = newLatencyHistogram(meter)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Summary-style sum and count of latencies, for backends that can't use
+	// histograms
+	if envBool("LATENCY_SUM_COUNTERS", false) {
+		a.latencySumCounter, err = meter.Float64Counter(
+			"api.request.latency.sum",
+			metric.WithDescription("Sum of request latencies in seconds"),
+			metric.WithUnit("{s}"),
+		)
+		if err != nil {
+			return err
+		}
+
+		a.latencyCountCounter, err = meter.Int64Counter(
+			"api.request.latency.count",
+			metric.WithDescription("Number of requests included in api.request.latency.sum."),
+			metric.WithUnit("{call}"),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Latency of calls to downstream services
+	a.clientDurationHistogram, err = meter.Float64Histogram(
+		"http.client.duration_seconds",
+		metric.WithDescription("Records the latency of outgoing HTTP requests in seconds"),
+		metric.WithUnit("{s}"),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Time spent in each logical phase of a handler
+	a.phaseHistogram, err = meter.Float64Histogram(
+		"app.handler.phase_seconds",
+		metric.WithDescription("Records the duration of handler phases in seconds"),
+		metric.WithUnit("{s}"),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Requests rejected for using the wrong HTTP method
+	a.methodNotAllowedCounter, err = meter.Int64Counter(
+		"api.request.method_not_allowed",
+		metric.WithDescription("Number of API calls rejected for using a disallowed method."),
+		metric.WithUnit("{call}"),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Request body bytes actually consumed by handlers
+	a.bodyBytesCounter, err = meter.Int64Counter(
+		"http.server.request.body.bytes_read",
+		metric.WithDescription("Number of request body bytes read by handlers."),
+		metric.WithUnit("By"),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Outcome of provider shutdowns
+	a.shutdownCounter, err = meter.Int64Counter(
+		"otel.sdk.shutdown",
+		metric.WithDescription("Number of telemetry provider shutdowns by outcome."),
+		metric.WithUnit("{shutdown}"),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Attributes per span, to spot instrumentation with runaway attributes
+	a.spanAttributeCountHistogram, err = meter.Int64Histogram(
+		"otel.span.attribute_count",
+		metric.WithDescription("Records the number of attributes on each ended span"),
+		metric.WithUnit("{attribute}"),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Spans lost between the batch processor and the exporter
+	a.droppedSpansCounter, err = meter.Int64Counter(
+		"otel.sdk.span.dropped",
+		metric.WithDescription("Number of ended spans that were never exported."),
+		metric.WithUnit("{span}"),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Incomplete traces dropped by the errors-only export mode
+	a.evictedTracesCounter, err = meter.Int64Counter(
+		"otel.span.errors_only.evicted",
+		metric.WithDescription("Number of pending traces evicted by the errors-only processor before all their spans ended."),
+		metric.WithUnit("{trace}"),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Requests rejected for exceeding the body size limit
+	a.bodyTooLargeCounter, err = meter.Int64Counter(
+		"api.request.body_too_large",
+		metric.WithDescription("Number of API calls rejected for an oversized request body."),
+		metric.WithUnit("{call}"),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Requests carrying a malformed traceparent header
+	a.invalidTraceContextCounter, err = meter.Int64Counter(
+		"trace.context.invalid",
+		metric.WithDescription("Number of requests with a malformed traceparent header."),
+		metric.WithUnit("{call}"),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Background worker
+	a.jobsProcessedCounter, err = meter.Int64Counter(
+		"worker.jobs.processed",
+		metric.WithDescription("Number of jobs processed by the background worker."),
+		metric.WithUnit("{job}"),
+	)
+	if err != nil {
+		return err
+	}
+
+	a.jobDurationHistogram, err = meter.Float64Histogram(
+		"worker.job.duration_seconds",
+		metric.WithDescription("Records the time taken to process a job in seconds"),
+		metric.WithUnit("{s}"),
+	)
+	if err != nil {
+		return err
+	}
+
+	a.jobQueueWaitHistogram, err = meter.Float64Histogram(
+		"worker.job.queue_wait_seconds",
+		metric.WithDescription("Records the time jobs wait in the queue before processing in seconds"),
+		metric.WithUnit("{s}"),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Age of the last successful export; a growing value means the pipeline is
+	// broken even when no export errors are counted.
+	_, err = meter.Float64ObservableGauge(
+		"otel.export.last_success.age_seconds",
+		metric.WithDescription("Seconds since telemetry was last exported successfully."),
+		metric.WithUnit("{s}"),
+		metric.WithFloat64Callback(
+			func(ctx context.Context, fo metric.Float64Observer) error {
+				fo.Observe(lastExportAge().Seconds())
+				return nil
+			},
+		),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Share of sampled spans that reached the backend, covering spans lost to
+	// full queues and failed exports
+	_, err = meter.Float64ObservableGauge(
+		"otel.span.delivery_ratio",
+		metric.WithDescription("Fraction of sampled spans that were exported successfully."),
+		metric.WithUnit("1"),
+		metric.WithFloat64Callback(
+			func(ctx context.Context, fo metric.Float64Observer) error {
+				if ratio, ok := spanDeliveryRatio(); ok {
+					fo.Observe(ratio)
+				}
+				return nil
+			},
+		),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Gauge
+	// Cart items
+	a.itemGauge, err = meter.Int64Gauge(
+		"api.cart.items",
+		metric.WithDescription("Tracks the number of items in a user's cart"),
+		metric.WithUnit("{item}"),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Carts with at least one item, read under the cart lock
+	_, err = meter.Int64ObservableGauge(
+		"api.cart.active_count",
+		metric.WithDescription("Number of non-empty carts."),
+		metric.WithUnit("{cart}"),
+		metric.WithInt64Callback(
+			func(ctx context.Context, o metric.Int64Observer) error {
+				o.Observe(a.activeCarts())
+				return nil
+			},
+		),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Net cart activity per collection interval, which shows engagement trends
+	// independently of the absolute cart size
+	_, err = meter.Int64ObservableUpDownCounter(
+		"api.cart.churn",
+		metric.WithDescription("Items added minus items removed since the previous collection."),
+		metric.WithUnit("{item}"),
+		metric.WithInt64Callback(
+			func(ctx context.Context, o metric.Int64Observer) error {
+				o.Observe(a.cartChurn.Load())
+				return nil
+			},
+		),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Net items added, so that the flow of items can be reconstructed
+	// regardless of when the api.cart.items gauge happens to be exported
+	a.cartSizeDeltaCounter, err = meter.Int64UpDownCounter(
+		"api.cart.size_delta",
+		metric.WithDescription("Items added to carts minus items removed."),
+		metric.WithUnit("{item}"),
+	)
+	if err != nil {
+		return err
+	}
+
+	a.cartRejectedAddsCounter, err = meter.Int64Counter(
+		"api.cart.rejected_adds",
+		metric.WithDescription("Number of cart adds rejected, by reason."),
+		metric.WithUnit("{add}"),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Stock levels read from a (simulated) external source
+	_, err = meter.Int64ObservableGauge(
+		"inventory.level",
+		metric.WithDescription("Stock level of each tracked inventory item."),
+		metric.WithUnit("{item}"),
+		metric.WithInt64Callback(observeInventory),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Process runtime metrics, read from the memory source named by
+	// MEMORY_SOURCE
+	if envBool("ENABLE_RUNTIME_METRICS", true) {
+		source, err := newMemorySource(os.Getenv("MEMORY_SOURCE"))
+		if err != nil {
+			return err
+		}
+		if err := registerRuntimeMetrics(meter, source); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
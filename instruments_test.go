@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// endedSpan returns the first ended span with the given name.
+func endedSpan(t *testing.T, spans *tracetest.SpanRecorder, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+
+	for _, span := range spans.Ended() {
+		if span.Name() == name {
+			return span
+		}
+	}
+	t.Fatalf("no %q span was ended", name)
+	return nil
+}
+
+// collectInt64Sum collects reader and returns the value of the named sum.
+func collectInt64Sum(t *testing.T, reader sdkmetric.Reader, name string) int64 {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if !ok {
+				t.Fatalf("%s is a %T, want an int64 sum", name, m.Data)
+			}
+			var total int64
+			for _, dp := range sum.DataPoints {
+				total += dp.Value
+			}
+			return total
+		}
+	}
+	t.Fatalf("no %s metric was collected", name)
+	return 0
+}
+
+// collectedNames collects reader and returns the names of the metrics it got.
+func collectedNames(t *testing.T, reader sdkmetric.Reader) map[string]bool {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]bool{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			names[m.Name] = true
+		}
+	}
+	return names
+}
+
+func TestRuntimeMetricsToggle(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		t.Setenv("ENABLE_RUNTIME_METRICS", strconv.FormatBool(enabled))
+		_, _, reader := newTestApp(t, config{})
+
+		var runtime []string
+		for name := range collectedNames(t, reader) {
+			if strings.HasPrefix(name, "process.") {
+				runtime = append(runtime, name)
+			}
+		}
+		if got := len(runtime) > 0; got != enabled {
+			t.Errorf("with runtime metrics enabled=%t, got runtime metrics %v", enabled, runtime)
+		}
+	}
+}
+
+// recordingMeter is a meter that remembers the name of every instrument
+// created with it.
+type recordingMeter struct {
+	metric.Meter
+	names map[string]bool
+}
+
+func (m recordingMeter) Int64Counter(name string, options ...metric.Int64CounterOption) (metric.Int64Counter, error) {
+	m.names[name] = true
+	return m.Meter.Int64Counter(name, options...)
+}
+
+func (m recordingMeter) Int64Histogram(name string, options ...metric.Int64HistogramOption) (metric.Int64Histogram, error) {
+	m.names[name] = true
+	return m.Meter.Int64Histogram(name, options...)
+}
+
+func (m recordingMeter) Int64Gauge(name string, options ...metric.Int64GaugeOption) (metric.Int64Gauge, error) {
+	m.names[name] = true
+	return m.Meter.Int64Gauge(name, options...)
+}
+
+func (m recordingMeter) Int64UpDownCounter(name string, options ...metric.Int64UpDownCounterOption) (metric.Int64UpDownCounter, error) {
+	m.names[name] = true
+	return m.Meter.Int64UpDownCounter(name, options...)
+}
+
+func (m recordingMeter) Int64ObservableUpDownCounter(name string, options ...metric.Int64ObservableUpDownCounterOption) (metric.Int64ObservableUpDownCounter, error) {
+	m.names[name] = true
+	return m.Meter.Int64ObservableUpDownCounter(name, options...)
+}
+
+func (m recordingMeter) Int64ObservableGauge(name string, options ...metric.Int64ObservableGaugeOption) (metric.Int64ObservableGauge, error) {
+	m.names[name] = true
+	return m.Meter.Int64ObservableGauge(name, options...)
+}
+
+func (m recordingMeter) Float64Counter(name string, options ...metric.Float64CounterOption) (metric.Float64Counter, error) {
+	m.names[name] = true
+	return m.Meter.Float64Counter(name, options...)
+}
+
+func (m recordingMeter) Float64Histogram(name string, options ...metric.Float64HistogramOption) (metric.Float64Histogram, error) {
+	m.names[name] = true
+	return m.Meter.Float64Histogram(name, options...)
+}
+
+func (m recordingMeter) Float64ObservableGauge(name string, options ...metric.Float64ObservableGaugeOption) (metric.Float64ObservableGauge, error) {
+	m.names[name] = true
+	return m.Meter.Float64ObservableGauge(name, options...)
+}
+
+func TestInstrumentsRegisteredBeforeServing(t *testing.T) {
+	t.Setenv("ENABLE_RUNTIME_METRICS", "true")
+	t.Setenv("LATENCY_SUM_COUNTERS", "true")
+	mp := sdkmetric.NewMeterProvider()
+	defer func() { _ = mp.Shutdown(context.Background()) }()
+	meter := recordingMeter{Meter: mp.Meter("test"), names: map[string]bool{}}
+
+	// What main does before it starts serving
+	if _, err := NewApp(config{}, noop.NewTracerProvider().Tracer("test"), meter, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"api.request.counter",
+		"api.request.canceled",
+		"api.request.latency_seconds",
+		"api.request.latency.sum",
+		"api.request.latency.count",
+		"api.request.method_not_allowed",
+		"api.request.error_counter",
+		"http.server.request.body.bytes_read",
+		"api.request.body_too_large",
+		"trace.context.invalid",
+		"http.client.duration_seconds",
+		"app.handler.phase_seconds",
+		"api.cart.items",
+		"api.cart.active_count",
+		"api.cart.churn",
+		"api.cart.rejected_adds",
+		"api.cart.size_delta",
+		"otel.sdk.shutdown",
+		"otel.span.attribute_count",
+		"otel.sdk.span.dropped",
+		"otel.span.errors_only.evicted",
+		"worker.jobs.processed",
+		"worker.job.duration_seconds",
+		"worker.job.queue_wait_seconds",
+		"otel.export.last_success.age_seconds",
+		"otel.span.delivery_ratio",
+		"inventory.level",
+		"process.allocated_memory",
+		"process.memory.pressure",
+		"process.runtime.gc.cpu_fraction",
+		"process.runtime.go.goroutines",
+		"process.runtime.go.gc.pause_ns",
+		"process.runtime.go.heap.objects",
+	}
+	for _, name := range want {
+		if !meter.names[name] {
+			t.Errorf("%s was not registered", name)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxInventoryItems bounds the number of items, and therefore attribute sets,
+// reported by the inventory.level gauge.
+const maxInventoryItems = 20
+
+// inventory stands in for an external data source such as a warehouse system.
+var inventory = struct {
+	sync.Mutex
+	levels map[string]int64
+}{levels: map[string]int64{}}
+
+// setStockLevel stores the stock level of item, reporting false when item is
+// new and the inventory is already tracking maxInventoryItems items.
+func setStockLevel(item string, level int64) bool {
+	inventory.Lock()
+	defer inventory.Unlock()
+
+	if _, ok := inventory.levels[item]; !ok && len(inventory.levels) >= maxInventoryItems {
+		return false
+	}
+	inventory.levels[item] = level
+	return true
+}
+
+// observeInventory reports the current stock level of every tracked item.
+func observeInventory(ctx context.Context, o metric.Int64Observer) error {
+	inventory.Lock()
+	defer inventory.Unlock()
+
+	for item, level := range inventory.levels {
+		o.Observe(level, metric.WithAttributes(attribute.String("item", item)))
+	}
+	return nil
+}
+
+// stockHandler refreshes the stock level of ?item=X from the simulated external
+// source. The inventory.level gauge picks it up at the next collection.
+func (a *App) stockHandler(w http.ResponseWriter, r *http.Request) {
+	span := trace.SpanFromContext(r.Context())
+
+	item := r.URL.Query().Get("item")
+	if item == "" {
+		http.Error(w, "Missing item query parameter", http.StatusBadRequest)
+		return
+	}
+
+	// Simulate reading the level from an external system
+	level := rand.Int64N(100)
+	if !setStockLevel(item, level) {
+		http.Error(w, "Too many tracked items", http.StatusBadRequest)
+		return
+	}
+
+	span.SetAttributes(
+		attribute.String("stockHandler.item", item),
+		attribute.Int64("stockHandler.level", level),
+	)
+
+	w.WriteHeader(http.StatusOK)
+	_, _ = fmt.Fprintf(w, "Stock level of %s: %d.", item, level)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestInventoryGauge(t *testing.T) {
+	const item = "test-widget"
+	defer func() {
+		inventory.Lock()
+		delete(inventory.levels, item)
+		inventory.Unlock()
+	}()
+	ctx := context.Background()
+
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer func() { _ = mp.Shutdown(ctx) }()
+	if _, err := mp.Meter("test").Int64ObservableGauge("inventory.level", metric.WithInt64Callback(observeInventory)); err != nil {
+		t.Fatal(err)
+	}
+
+	level := func() int64 {
+		t.Helper()
+		var rm metricdata.ResourceMetrics
+		if err := reader.Collect(ctx, &rm); err != nil {
+			t.Fatal(err)
+		}
+		for _, dp := range rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Gauge[int64]).DataPoints {
+			if value, _ := dp.Attributes.Value("item"); value.AsString() == item {
+				return dp.Value
+			}
+		}
+		t.Fatalf("no inventory.level data point for %s", item)
+		return 0
+	}
+
+	for _, want := range []int64{5, 7} {
+		if !setStockLevel(item, want) {
+			t.Fatal("the inventory is full")
+		}
+		if got := level(); got != want {
+			t.Errorf("inventory.level = %d, want %d", got, want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// linkServerSession makes every root span link to the server session span.
+var linkServerSession = envBool("LINK_SERVER_SESSION", false)
+
+var (
+	// serverSession spans the whole lifetime of the server, with an event for
+	// each major lifecycle point, so the backend shows one "server session".
+	serverSession trace.Span = noop.Span{}
+
+	firstRequest sync.Once
+)
+
+// startServerSession starts the server session span with a's tracer.
+func (a *App) startServerSession(ctx context.Context) {
+	_, serverSession = a.tracer.Start(ctx, "server session", trace.WithNewRoot())
+}
+
+// markLifecycle records a lifecycle event on the server session span.
+func markLifecycle(event string) {
+	serverSession.AddEvent(event)
+}
+
+// markFirstRequest records the first request event once.
+func markFirstRequest() {
+	firstRequest.Do(func() { markLifecycle("first request") })
+}
+
+// endServerSession records the shutdown event and ends the server session
+// span.
+func endServerSession() {
+	markLifecycle("shutdown")
+	serverSession.End()
+}
+
+// serverSessionLinkProcessor links root spans to the server session span.
+type serverSessionLinkProcessor struct{ noopProcessor }
+
+func (serverSessionLinkProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	session := serverSession.SpanContext()
+	if !session.IsValid() || trace.SpanContextFromContext(parent).IsValid() {
+		return
+	}
+	s.AddLink(trace.Link{SpanContext: session})
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestServerSessionEvents(t *testing.T) {
+	defer func() {
+		serverSession = noop.Span{}
+		firstRequest = sync.Once{}
+	}()
+	defer draining.Store(false)
+	firstRequest = sync.Once{}
+	t.Setenv("ENABLE_DRAIN", "true")
+	app, spans, _ := newTestApp(t, config{})
+
+	// The lifecycle main goes through
+	app.startServerSession(context.Background())
+	markLifecycle("init complete")
+	server, _, err := NewServer(app, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	server.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	server.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/drain", nil))
+	endServerSession()
+
+	var events []string
+	for _, event := range endedSpan(t, spans, "server session").Events() {
+		events = append(events, event.Name)
+	}
+	want := []string{"init complete", "first request", "drain started", "shutdown"}
+	if !slices.Equal(events, want) {
+		t.Errorf("server session events = %q, want %q", events, want)
+	}
+}
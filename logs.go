@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+
+	"go.opentelemetry.io/contrib/bridges/otelslog"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// logger writes structured logs to stderr and, once initLoggerProvider has
+// run, also exports them through the OpenTelemetry logs signal. Records logged
+// with a context carry the trace_id and span_id of its active span.
+var logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+// Logs can be far more voluminous than spans, so their batching is tuned
+// separately from the span processor.
+var (
+	logBatchSize   = envInt("OTEL_BLRP_MAX_EXPORT_BATCH_SIZE", 512)
+	logQueueSize   = envInt("OTEL_BLRP_MAX_QUEUE_SIZE", 2048)
+	logCompression = envString("OTEL_EXPORTER_OTLP_LOGS_COMPRESSION", "gzip")
+)
+
+// initLoggerProvider configures the logger provider to export to every target
+// and points logger, and the standard library's log package, at it, logging
+// under the name of the service.
+func initLoggerProvider(ctx context.Context, serviceName string, res *resource.Resource, targets []otlpTarget) (func(context.Context) error, error) {
+	logExporters, err := logExporters(ctx, targets)
+	if err != nil {
+		return nil, err
+	}
+
+	loggerProvider := newLoggerProvider(res, logExporters)
+	if err := installLoggerProvider(ctx, loggerProvider); err != nil {
+		_ = loggerProvider.Shutdown(ctx)
+		return nil, err
+	}
+
+	stderrLogger := logger
+	logger = slog.New(teeHandler{
+		stderrLogger.Handler(),
+		otelslog.NewHandler(serviceName, otelslog.WithLoggerProvider(loggerProvider)),
+	})
+	slog.SetDefault(logger)
+
+	return func(ctx context.Context) error {
+		err := loggerProvider.Shutdown(ctx)
+		// Anything logged from here on, such as the outcome of this shutdown,
+		// would otherwise be dropped.
+		logger = stderrLogger
+		slog.SetDefault(logger)
+		return err
+	}, nil
+}
+
+// newLoggerProvider returns a logger provider that batches records for each
+// of exporters, attaching res to every record so that logs viewed outside
+// the backend still name the service that wrote them.
+func newLoggerProvider(res *resource.Resource, exporters []sdklog.Exporter) *sdklog.LoggerProvider {
+	opts := []sdklog.LoggerProviderOption{sdklog.WithResource(res)}
+	for _, exporter := range exporters {
+		opts = append(opts, sdklog.WithProcessor(sdklog.NewBatchProcessor(exporter,
+			sdklog.WithExportMaxBatchSize(logBatchSize),
+			sdklog.WithMaxQueueSize(logQueueSize),
+		)))
+	}
+	return sdklog.NewLoggerProvider(opts...)
+}
+
+// fatal logs err and exits, flushing the logger provider first so the record
+// isn't lost.
+func fatal(msg string, err error) {
+	logger.Error(msg, "error", err)
+
+	providersMu.Lock()
+	if installedLoggerProvider != nil {
+		_ = installedLoggerProvider.Shutdown(context.Background())
+	}
+	providersMu.Unlock()
+
+	os.Exit(1)
+}
+
+// teeHandler passes each record to every handler enabled for its level.
+type teeHandler []slog.Handler
+
+func (t teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range t {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t teeHandler) Handle(ctx context.Context, record slog.Record) error {
+	var errs []error
+	for _, h := range t {
+		if h.Enabled(ctx, record.Level) {
+			errs = append(errs, h.Handle(ctx, record.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (t teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(teeHandler, len(t))
+	for i, h := range t {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (t teeHandler) WithGroup(name string) slog.Handler {
+	handlers := make(teeHandler, len(t))
+	for i, h := range t {
+		handlers[i] = h.WithGroup(name)
+	}
+	return handlers
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/contrib/bridges/otelslog"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// recordingLogExporter keeps every exported log record.
+type recordingLogExporter struct {
+	mu      sync.Mutex
+	records []sdklog.Record
+}
+
+func (e *recordingLogExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, record := range records {
+		e.records = append(e.records, record.Clone())
+	}
+	return nil
+}
+
+func (e *recordingLogExporter) Shutdown(context.Context) error { return nil }
+
+func (e *recordingLogExporter) ForceFlush(context.Context) error { return nil }
+
+func TestLogRecordResource(t *testing.T) {
+	ctx := context.Background()
+	res, err := newResource(ctx, defaultServiceName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exporter := &recordingLogExporter{}
+	lp := newLoggerProvider(res, []sdklog.Exporter{exporter})
+	defer func() { _ = lp.Shutdown(ctx) }()
+
+	slog.New(otelslog.NewHandler(defaultServiceName, otelslog.WithLoggerProvider(lp))).InfoContext(ctx, "hello")
+	if err := lp.ForceFlush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(exporter.records) != 1 {
+		t.Fatalf("got %d log records, want 1", len(exporter.records))
+	}
+	got := exporter.records[0].Resource()
+	if value, ok := got.Set().Value("service.name"); !ok || value.AsString() != defaultServiceName {
+		t.Errorf("log record resource %v has no service.name=%s", got, defaultServiceName)
+	}
+}
+
+// newTestTarget returns a gRPC collector target that is never used to export.
+func newTestTarget(t *testing.T) otlpTarget {
+	t.Helper()
+
+	conn, err := grpc.NewClient("localhost:4317", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return otlpTarget{endpoint: "localhost:4317", conn: conn}
+}
+
+func TestLoggerProviderBatchSettings(t *testing.T) {
+	defer func(size, queue int) { logBatchSize, logQueueSize = size, queue }(logBatchSize, logQueueSize)
+	logBatchSize, logQueueSize = 64, 256
+	ctx := context.Background()
+
+	exporter, err := newLogExporter(ctx, newTestTarget(t))
+	if err != nil {
+		t.Fatalf("newLogExporter: %v", err)
+	}
+	lp := newLoggerProvider(resource.Empty(), []sdklog.Exporter{exporter})
+	if err := lp.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown: %v", err)
+	}
+}
+
+func TestLogCompressionIgnoredOverGRPC(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_LOGS_COMPRESSION", "gzip")
+	defer log.SetOutput(log.Writer())
+	var out bytes.Buffer
+	log.SetOutput(&out)
+
+	exporter, err := newLogExporter(context.Background(), newTestTarget(t))
+	if err != nil {
+		t.Fatalf("newLogExporter: %v", err)
+	}
+	_ = exporter.Shutdown(context.Background())
+
+	if !strings.Contains(out.String(), "Ignoring OTEL_EXPORTER_OTLP_LOGS_COMPRESSION") {
+		t.Errorf("no warning was logged, got %q", out.String())
+	}
+}
+
+func TestTeeHandler(t *testing.T) {
+	ctx := context.Background()
+	exporter := &recordingLogExporter{}
+	lp := newLoggerProvider(resource.Empty(), []sdklog.Exporter{exporter})
+	defer func() { _ = lp.Shutdown(ctx) }()
+
+	var out bytes.Buffer
+	l := slog.New(teeHandler{
+		slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelWarn}),
+		otelslog.NewHandler(defaultServiceName, otelslog.WithLoggerProvider(lp)),
+	}).With("component", "test")
+	l.InfoContext(ctx, "starting")
+	l.WarnContext(ctx, "degraded")
+	if err := lp.ForceFlush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := out.String(); strings.Contains(got, "starting") || !strings.Contains(got, "msg=degraded component=test") {
+		t.Errorf("stderr handler wrote %q, want only the warning with its attributes", got)
+	}
+	if len(exporter.records) != 2 {
+		t.Fatalf("exported %d records, want 2", len(exporter.records))
+	}
+}
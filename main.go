@@ -2,47 +2,47 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"math/rand/v2"
 	"net/http"
+	"os"
+	"os/signal"
 	"runtime"
+	"slices"
+	"strconv"
+	"syscall"
 	"time"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/prometheus"
 	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/propagation"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/trace"
 )
 
-var (
-	serviceName      string = "test-service"
-	collectorURL     string = "localhost:4317"
-	meter            metric.Meter
-	errorCounter     metric.Int64Counter
-	latencyHistogram metric.Float64Histogram
-	itemGauge        metric.Int64Gauge
-	cartCount        int64 = 0
-	tracer           trace.Tracer
-)
-
 // Initialize a gRPC connection to be used by both the tracer and meter providers.
-func initGrpcConn() (*grpc.ClientConn, error) {
-	// It connects the OpenTelemetry Collector through local gRPC connection.
-	conn, err := grpc.NewClient(
-		collectorURL,
-		// Note the use of insecure transport here. TLS is recommended in production.
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-	)
+func initGrpcConn(endpoint string) (*grpc.ClientConn, error) {
+	if err := validateEndpoint(endpoint); err != nil {
+		return nil, err
+	}
+
+	creds, err := grpcTransportCredentials(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	// It connects the OpenTelemetry Collector through gRPC, over TLS unless
+	// the collector is local or OTEL_EXPORTER_OTLP_INSECURE is set.
+	conn, err := grpc.NewClient(endpoint, grpc.WithTransportCredentials(creds))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create gRPC connection to collector: %w", err)
 	}
@@ -50,235 +50,658 @@ func initGrpcConn() (*grpc.ClientConn, error) {
 	return conn, err
 }
 
-// Initializes an OTLP exporter, and configures the corresponding meter provider.
-func initMeterProvider(ctx context.Context, res *resource.Resource, conn *grpc.ClientConn) (func(context.Context) error, error) {
-	metricExporter, err := otlpmetricgrpc.New(ctx, otlpmetricgrpc.WithGRPCConn(conn))
+// applyMetricCardinalityLimit caps the distinct attribute sets of every
+// instrument at METRIC_CARDINALITY_LIMIT. Beyond it, the SDK folds measurements
+// into a single otel.metric.overflow=true data point. This SDK version only
+// exposes the limit through its experimental OTEL_GO_X_CARDINALITY_LIMIT env
+// var, which it reads whenever a meter provider is created. The limit is
+// therefore process-wide, covering the runtime gauges too, and has to be set
+// before the first provider is.
+func applyMetricCardinalityLimit() error {
+	limit := envInt("METRIC_CARDINALITY_LIMIT", 0)
+	if limit <= 0 {
+		return nil
+	}
+	if err := os.Setenv("OTEL_GO_X_CARDINALITY_LIMIT", strconv.Itoa(limit)); err != nil {
+		return fmt.Errorf("failed to apply METRIC_CARDINALITY_LIMIT: %w", err)
+	}
+	log.Printf("Limiting every instrument to %d attribute sets", limit)
+	return nil
+}
+
+// Initializes an exporter per target, or one to stdout, and configures the
+// corresponding meter provider. When auto-tuning is enabled, it also returns
+// the tuner that backs the latency histogram.
+func initMeterProvider(ctx context.Context, res *resource.Resource, targets []otlpTarget, interval time.Duration) (func(context.Context) error, *bucketTuner, error) {
+	overrides, err := instrumentOverrideViews(os.Getenv("INSTRUMENT_OVERRIDES"))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create metrics exporter: %w", err)
+		return nil, nil, err
 	}
+	views := append([]sdkmetric.View{latencyHistogramView(), requestMetricsAttributeView()}, overrides...)
 
-	meterProvider := sdkmetric.NewMeterProvider(
-		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter,
-			// Default is 1m. Set to 3s for demonstrative purposes.
-			sdkmetric.WithInterval(3*time.Second))),
-		sdkmetric.WithResource(res),
-	)
-	otel.SetMeterProvider(meterProvider)
+	log.Printf("Exporting metrics every %s", interval)
 
-	return meterProvider.Shutdown, nil
-}
+	providerOptions := func(extra ...sdkmetric.View) ([]sdkmetric.Option, error) {
+		opts := []sdkmetric.Option{
+			sdkmetric.WithResource(res),
+			sdkmetric.WithView(combineViews(slices.Concat(views, extra)...)),
+		}
+		metricExporters, err := metricExporters(ctx, targets)
+		if err != nil {
+			return nil, err
+		}
+		for _, metricExporter := range metricExporters {
+			opts = append(opts, sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter,
+				sdkmetric.WithInterval(interval))))
+		}
+		return opts, nil
+	}
+	newProvider := func(extra ...sdkmetric.View) (*sdkmetric.MeterProvider, error) {
+		opts, err := providerOptions(extra...)
+		if err != nil {
+			return nil, err
+		}
+		return sdkmetric.NewMeterProvider(opts...), nil
+	}
 
-func initTraceProvider(ctx context.Context, res *resource.Resource, conn *grpc.ClientConn) (func(context.Context) error, error) {
-	traceExporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithGRPCConn(conn))
+	opts, err := providerOptions()
 	if err != nil {
-		log.Fatalf("Failed to create exporter: %v", err)
+		return nil, nil, err
+	}
+	// The Prometheus reader sits next to the OTLP ones so that both see the
+	// same instruments. A reader can only belong to one provider, so it misses
+	// the auto-tuned latency histogram, which has a provider of its own.
+	if prometheusEnabled {
+		promExporter, err := prometheus.New()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create Prometheus exporter: %w", err)
+		}
+		opts = append(opts, sdkmetric.WithReader(promExporter))
 	}
 
-	traceProvider := sdktrace.NewTracerProvider(
-		sdktrace.WithSampler(sdktrace.AlwaysSample()),
-		sdktrace.WithBatcher(traceExporter),
+	meterProvider := sdkmetric.NewMeterProvider(opts...)
+	if err := installMeterProvider(ctx, meterProvider); err != nil {
+		_ = meterProvider.Shutdown(ctx)
+		return nil, nil, err
+	}
+
+	if !latencyAutoTune {
+		return meterProvider.Shutdown, nil, nil
+	}
+
+	// The tuned latency histogram gets a provider of its own, which is rebuilt
+	// with new buckets once warmup is over.
+	latencyTuner := newBucketTuner(latencyAutoTuneWarmup, latencyAutoTuneBuckets, newProvider)
+	return func(ctx context.Context) error {
+		return errors.Join(latencyTuner.shutdown(ctx), meterProvider.Shutdown(ctx))
+	}, latencyTuner, nil
+}
+
+// propagator carries W3C trace context and baggage across process boundaries.
+var propagator = propagation.NewCompositeTextMapPropagator(
+	propagation.TraceContext{},
+	propagation.Baggage{},
+)
+
+// newBatchSpanProcessor batches spans to exporter, reporting any spans that
+// are dropped on the way in dropped, if not nil.
+func newBatchSpanProcessor(exporter sdktrace.SpanExporter, dropped metric.Int64Counter, opts ...sdktrace.BatchSpanProcessorOption) sdktrace.SpanProcessor {
+	tracker := newSpanDeliveryTracker()
+	batcher := sdktrace.NewBatchSpanProcessor(&trackingSpanExporter{SpanExporter: exporter, tracker: tracker}, opts...)
+	return &trackingSpanProcessor{SpanProcessor: batcher, tracker: tracker, dropped: dropped}
+}
+
+// batchSpanProcessorOptions tunes the span batcher from
+// OTEL_BSP_MAX_QUEUE_SIZE, OTEL_BSP_MAX_EXPORT_BATCH_SIZE and
+// OTEL_BSP_SCHEDULE_DELAY. Per the spec, the delay is in milliseconds. The SDK
+// defaults are kept for any that are unset.
+func batchSpanProcessorOptions() []sdktrace.BatchSpanProcessorOption {
+	var opts []sdktrace.BatchSpanProcessorOption
+	if size := envInt("OTEL_BSP_MAX_QUEUE_SIZE", 0); size > 0 {
+		opts = append(opts, sdktrace.WithMaxQueueSize(size))
+	}
+	if size := envInt("OTEL_BSP_MAX_EXPORT_BATCH_SIZE", 0); size > 0 {
+		opts = append(opts, sdktrace.WithMaxExportBatchSize(size))
+	}
+	if delay := envMillisDuration("OTEL_BSP_SCHEDULE_DELAY", 0); delay > 0 {
+		opts = append(opts, sdktrace.WithBatchTimeout(delay))
+	}
+	return opts
+}
+
+// tracerProviderOptions configures a tracer provider that exports to every
+// one of exporters, reporting on its spans with a's instruments.
+func tracerProviderOptions(a *App, res *resource.Resource, exporters []sdktrace.SpanExporter) []sdktrace.TracerProviderOption {
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(breakerSampler{
+			base:    prioritySampler{base: samplerFromEnv()},
+			breaker: exportBreaker,
+		}),
+		sdktrace.WithSpanProcessor(correlationIDProcessor{}),
+		sdktrace.WithSpanProcessor(invalidTraceContextProcessor{}),
+		sdktrace.WithSpanProcessor(attributeCountProcessor{histogram: a.spanAttributeCountHistogram}),
 		sdktrace.WithResource(res),
-	)
-	otel.SetTracerProvider(traceProvider)
+	}
+	batchOpts := batchSpanProcessorOptions()
+	for _, exporter := range exporters {
+		var processor sdktrace.SpanProcessor = newBatchSpanProcessor(exporter, a.droppedSpansCounter, batchOpts...)
+		if exportErrorsOnly {
+			processor = newErrorsOnlyProcessor(processor, errorsOnlyMaxTraces, errorsOnlyMaxAge, a.evictedTracesCounter)
+		}
+		opts = append(opts, sdktrace.WithSpanProcessor(processor))
+	}
 
-	otel.SetTextMapPropagator(
-		propagation.NewCompositeTextMapPropagator(
-			propagation.TraceContext{},
-			propagation.Baggage{},
-		),
-	)
+	if canary {
+		opts = append(opts, sdktrace.WithSpanProcessor(canaryProcessor{}))
+	}
+	if a.cfg.debug {
+		opts = append(opts, sdktrace.WithSpanProcessor(recentSpans))
+	}
+	if linkServerSession {
+		opts = append(opts, sdktrace.WithSpanProcessor(serverSessionLinkProcessor{}))
+	}
+
+	return opts
+}
+
+// initTraceProvider configures the tracer provider to export to every target.
+// a's tracer starts recording through it once it is installed.
+func initTraceProvider(ctx context.Context, a *App, res *resource.Resource, targets []otlpTarget) (func(context.Context) error, error) {
+	traceExporters, err := traceExporters(ctx, targets)
+	if err != nil {
+		return nil, err
+	}
+
+	traceProvider := sdktrace.NewTracerProvider(tracerProviderOptions(a, res, traceExporters)...)
+	if err := installTracerProvider(ctx, traceProvider); err != nil {
+		_ = traceProvider.Shutdown(ctx)
+		return nil, err
+	}
+
+	otel.SetTextMapPropagator(propagator)
 
 	return traceProvider.Shutdown, nil
 }
 
-func collectMachineResourceMetrics(meter metric.Meter) {
-	period := 5 * time.Second
-	ticker := time.NewTicker(period)
+// memoryPressureThreshold is the HeapInuse/Sys ratio above which the process
+// is reported as under memory pressure.
+var memoryPressureThreshold = envFloat("MEMORY_PRESSURE_THRESHOLD", 0.9)
+
+// memoryPressure returns 1 when the in-use heap exceeds threshold as a
+// fraction of the memory obtained from the OS, and 0 otherwise.
+func memoryPressure(memStats *runtime.MemStats, threshold float64) int64 {
+	if memStats.Sys == 0 {
+		return 0
+	}
+	if float64(memStats.HeapInuse)/float64(memStats.Sys) > threshold {
+		return 1
+	}
+	return 0
+}
+
+// registerRuntimeMetrics registers the process runtime gauges, reading
+// allocated memory from source. They are observed on the SDK's own collection
+// cadence, so each is registered exactly once.
+func registerRuntimeMetrics(meter metric.Meter, source memorySource) error {
+	_, err := meter.Float64ObservableGauge(
+		"process.allocated_memory",
+		metric.WithDescription("Allocated memory in MB."),
+		metric.WithUnit("{MB}"),
+		metric.WithFloat64Callback(allocatedMemoryCallback(source)),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Memory pressure as a 0/1 gauge, so alerts can fire without backend math
+	memoryPressureGauge, err := meter.Int64ObservableGauge(
+		"process.memory.pressure",
+		metric.WithDescription("1 when the in-use heap exceeds the memory pressure threshold, 0 otherwise."),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Fraction of CPU time spent in GC since the program started
+	gcCPUFractionGauge, err := meter.Float64ObservableGauge(
+		"process.runtime.gc.cpu_fraction",
+		metric.WithDescription("Fraction of the available CPU time used by the GC since the program started."),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return err
+	}
+
+	goroutinesGauge, err := meter.Int64ObservableGauge(
+		"process.runtime.go.goroutines",
+		metric.WithDescription("Number of goroutines that currently exist."),
+		metric.WithUnit("{goroutine}"),
+	)
+	if err != nil {
+		return err
+	}
+
+	gcPauseGauge, err := meter.Int64ObservableGauge(
+		"process.runtime.go.gc.pause_ns",
+		metric.WithDescription("Duration of the most recent GC stop-the-world pause."),
+		metric.WithUnit("ns"),
+	)
+	if err != nil {
+		return err
+	}
 
+	heapObjectsGauge, err := meter.Int64ObservableGauge(
+		"process.runtime.go.heap.objects",
+		metric.WithDescription("Number of allocated heap objects."),
+		metric.WithUnit("{object}"),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Read MemStats once per collection for all of the gauges above
+	_, err = meter.RegisterCallback(
+		func(ctx context.Context, o metric.Observer) error {
+			var memStats runtime.MemStats
+			runtime.ReadMemStats(&memStats)
+
+			o.ObserveInt64(memoryPressureGauge, memoryPressure(&memStats, memoryPressureThreshold))
+			o.ObserveFloat64(gcCPUFractionGauge, memStats.GCCPUFraction)
+			o.ObserveInt64(goroutinesGauge, int64(runtime.NumGoroutine()))
+			o.ObserveInt64(heapObjectsGauge, int64(memStats.HeapObjects))
+
+			// PauseNs is a ring buffer; the latest pause is at (NumGC+255)%256
+			if memStats.NumGC > 0 {
+				o.ObserveInt64(gcPauseGauge, int64(memStats.PauseNs[(memStats.NumGC+255)%256]))
+			}
+
+			return nil
+		},
+		memoryPressureGauge,
+		gcCPUFractionGauge,
+		goroutinesGauge,
+		gcPauseGauge,
+		heapObjectsGauge,
+	)
+	return err
+}
+
+// allocatedMemoryCallback observes the memory source reports as in use, in MB.
+func allocatedMemoryCallback(source memorySource) metric.Float64Callback {
 	var Mb uint64 = 1_048_576 // number of bytes in a MB
 
-	for {
-		select {
-		case <-ticker.C:
-			// This will be executed every "period" of time passes
-			meter.Float64ObservableGauge(
-				"process.allocated_memory",
-				metric.WithDescription("Allocated memory in MB."),
-				metric.WithUnit("{MB}"),
-				metric.WithFloat64Callback(
-					func(ctx context.Context, fo metric.Float64Observer) error {
-						var memStats runtime.MemStats
-						runtime.ReadMemStats(&memStats)
-
-						allocatedMemoryInMB := float64(memStats.Alloc) / float64(Mb)
-						fo.Observe(allocatedMemoryInMB)
-
-						return nil
-					},
-				),
-			)
+	return func(ctx context.Context, fo metric.Float64Observer) error {
+		allocated, err := source.AllocatedBytes()
+		if err != nil {
+			return err
 		}
+
+		allocatedMemoryInMB := float64(allocated) / float64(Mb)
+		fo.Observe(allocatedMemoryInMB)
+
+		return nil
 	}
 }
 
 func main() {
 	ctx := context.Background()
+	cfg := parseFlags(os.Args[1:])
+	loadConfig(cfg)
 
-	conn, err := initGrpcConn()
+	// Give a collector sidecar that starts alongside the app time to come up
+	// before any exporter or provider is created.
+	delayStartup(startupDelay)
+
+	if err := applyMetricCardinalityLimit(); err != nil {
+		fatal("failed to apply metric cardinality limit", err)
+	}
+
+	// No collector is contacted at all when printing telemetry to stdout
+	var targets []otlpTarget
+	if !stdoutExport {
+		target, err := newOTLPTarget(ctx, cfg.endpoint)
+		if err != nil {
+			fatal("failed to create collector target", err)
+		}
+		collector = target
+		targets = append(targets, target)
+
+		// Optionally dual-write telemetry to a second collector, e.g. while
+		// migrating between backends.
+		if cfg.secondaryEndpoint != "" {
+			log.Printf("Also exporting telemetry to %s", cfg.secondaryEndpoint)
+			secondary, err := newOTLPTarget(ctx, cfg.secondaryEndpoint)
+			if err != nil {
+				fatal("failed to create secondary collector target", err)
+			}
+			targets = append(targets, secondary)
+		}
+	}
+
+	if err := connectCollectors(ctx, targets); err != nil {
+		fatal("collector is required", err)
+	}
+
+	// Attributes set by the deployer win over the defaults
+	base, err := envResource(ctx)
+	if err != nil {
+		fatal("failed to parse OTEL_RESOURCE_ATTRIBUTES", err)
+	}
+	res, err := newResource(ctx, cfg.serviceName, base)
 	if err != nil {
-		log.Fatal(err)
+		fatal("failed to create resource", err)
 	}
 
-	res, err := resource.New(ctx,
-		resource.WithAttributes(
-			// The service name used to display traces in backends
-			attribute.String("service.name", serviceName),
-			attribute.String("library.language", "go"),
-		),
-	)
+	// Each signal may override service.name, e.g. to route it differently
+	metricsRes, err := signalResource(res, "OTEL_METRICS_SERVICE_NAME")
+	if err != nil {
+		fatal("failed to create metrics resource", err)
+	}
+	tracesRes, err := signalResource(res, "OTEL_TRACES_SERVICE_NAME")
+	if err != nil {
+		fatal("failed to create traces resource", err)
+	}
+
+	// The logger provider is set up first so that it is shut down last, after
+	// the meter provider has flushed the dropped span count reported by the
+	// tracer provider.
+	shutdownLoggerProvider, err := initLoggerProvider(ctx, cfg.serviceName, res, targets)
 	if err != nil {
-		log.Fatal(err)
+		fatal("failed to initialize logger provider", err)
 	}
 
-	shutdownTraceProvider, err := initTraceProvider(ctx, res, conn)
+	shutdownMeterProvider, latencyTuner, err := initMeterProvider(ctx, metricsRes, targets, cfg.metricInterval)
 	if err != nil {
-		log.Fatal(err)
+		fatal("failed to initialize meter provider", err)
+	}
+
+	// The App, and with it every instrument, is created before the tracer
+	// provider, whose span processors report through some of them. Its tracer
+	// records through the tracer provider as soon as that is installed.
+	app, err := NewApp(cfg, otel.Tracer(cfg.serviceName), otel.Meter(cfg.serviceName), latencyTuner)
+	if err != nil {
+		fatal("failed to register instruments", err)
 	}
 	defer func() {
-		if err := shutdownTraceProvider(ctx); err != nil {
-			log.Fatalf("failed to shutdown Tracer: %s", err)
-		}
+		app.recordShutdown(ctx, "LoggerProvider", shutdownLoggerProvider(ctx))
+	}()
+	defer func() {
+		app.recordShutdown(ctx, "MeterProvider", shutdownMeterProvider(ctx))
 	}()
 
-	shutdownMeterProvider, err := initMeterProvider(ctx, res, conn)
+	shutdownTraceProvider, err := initTraceProvider(ctx, app, tracesRes, targets)
 	if err != nil {
-		log.Fatal(err)
+		fatal("failed to initialize tracer provider", err)
 	}
 	defer func() {
-		if err := shutdownMeterProvider(ctx); err != nil {
-			log.Fatalf("failed to shutdown MeterProvider: %s", err)
-		}
+		app.recordShutdown(ctx, "TracerProvider", shutdownTraceProvider(ctx))
 	}()
 
-	// Create a Tracer
-	tracer = otel.Tracer(serviceName)
+	// Span covering the whole server lifetime
+	app.startServerSession(ctx)
+	defer endServerSession()
 
-	// Create a Meter
-	meter = otel.Meter(serviceName)
+	// Stop on SIGINT/SIGTERM so that the deferred shutdowns flush telemetry
+	sigCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Initialize metrics
-	// Count
-	errorCounter, err = meter.Int64Counter(
-		"api.request.error_counter",
-		metric.WithDescription("Number of erroneous API calls."),
-		metric.WithUnit("{call}"),
-	)
-	if err != nil {
-		log.Fatal(err)
-	}
+	go app.runWorker(sigCtx)
 
-	// Histogram
-	latencyHistogram, err = meter.Float64Histogram(
-		"api.request.latency_seconds",
-		metric.WithDescription("Records the latency of requests in seconds"),
-		metric.WithUnit("{s}"),
-	)
+	// Start HTTP server
+	server, _, err := NewServer(app, cfg.addr, nil)
 	if err != nil {
-		log.Fatal(err)
+		fatal("failed to create server", err)
 	}
+	markLifecycle("init complete")
+	logger.Info("Starting server", "addr", cfg.addr)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal("failed to start server", err)
+		}
+	}()
 
-	// Gauge
-	// Memory
-	go collectMachineResourceMetrics(meter)
-	// Cart items
-	itemGauge, err = meter.Int64Gauge(
-		"api.cart.items",
-		metric.WithDescription("Tracks the number of items in a user's cart"),
-		metric.WithUnit("{item}"),
-	)
-	if err != nil {
-		log.Fatal(err)
+	var adminServer *http.Server
+	if cfg.adminAddr != "" {
+		adminServer = NewAdminServer(app, cfg.adminAddr)
+		logger.Info("Starting admin server", "addr", cfg.adminAddr)
+		go func() {
+			if err := adminServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				fatal("failed to start admin server", err)
+			}
+		}()
 	}
 
-	// Start HTTP server
-	http.HandleFunc("/", helloWorldHandler)
-	http.HandleFunc("/cart/add", cartAddHandler)
-	http.HandleFunc("/cart/remove", cartRemoveHandler)
-	fmt.Println("Starting server on localhost:8080")
-	if err := http.ListenAndServe(":8080", nil); err != nil {
-		log.Fatalf("failed to start server: %v", err)
+	<-sigCtx.Done()
+	log.Println("Shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("failed to shutdown server: %v", err)
+	}
+	// The admin server goes last so probes keep answering while requests drain.
+	if adminServer != nil {
+		if err := adminServer.Shutdown(shutdownCtx); err != nil {
+			log.Printf("failed to shutdown admin server: %v", err)
+		}
 	}
 }
 
-// recordLatencyHistogram records the request latency
-func recordLatencyHistogram(start time.Time) {
+// recordLatencyHistogram records the request latency. ctx should carry the
+// request's span so the measurement can be linked to its trace as an exemplar.
+func (a *App) recordLatencyHistogram(ctx context.Context, start time.Time, attrs ...attribute.KeyValue) {
 	latency := time.Since(start).Seconds()
-	latencyHistogram.Record(context.Background(), latency)
+	opt := withMetricAttributes(attrs...)
+	a.latencyHistogram.Record(ctx, latency, opt)
+
+	// For backends without histogram support, average latency is sum / count
+	if a.latencySumCounter != nil {
+		a.latencySumCounter.Add(ctx, latency, opt)
+		a.latencyCountCounter.Add(ctx, 1, opt)
+	}
 }
 
-// helloWorldHandler handles the API request and returns "Hello, World!"
-func helloWorldHandler(w http.ResponseWriter, r *http.Request) {
-	_, span := tracer.Start(r.Context(), "helloWorldHandler")
+// recordStackTraces makes recordError capture a stack trace with each error.
+// It is off by default because capturing stacks adds overhead.
+var recordStackTraces = envBool("RECORD_STACK_TRACES", false)
+
+// recordError records err as an exception event on span.
+func recordError(span trace.Span, err error) {
+	span.RecordError(err, trace.WithStackTrace(recordStackTraces))
+}
+
+// runPhase runs fn in a child span named after the phase and records how long
+// it took in the phase histogram.
+func (a *App) runPhase(ctx context.Context, phase string, fn func(ctx context.Context)) {
+	ctx, span := a.tracer.Start(ctx, phase)
 	defer span.End()
 
 	start := time.Now()
-	defer recordLatencyHistogram(start)
+	fn(ctx)
+	a.phaseHistogram.Record(ctx, time.Since(start).Seconds(),
+		metric.WithAttributes(attribute.String("phase", phase)))
+}
 
-	// Simulate a potential error
-	if rand.Float64() < 0.5 { // 50% chance of an error
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		errorCounter.Add(r.Context(), 1)
+// simulateWork sleeps for a duration drawn uniformly from 50% to 150% of the
+// simulated latency. It stops early, returning ctx's error, when the request
+// is canceled, e.g. because the client disconnected.
+func (a *App) simulateWork(ctx context.Context) error {
+	latency := a.cfg.simulatedLatency
+	if latency <= 0 {
+		return nil
+	}
+	select {
+	case <-time.After(latency/2 + rand.N(latency)):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// statusClientClosedRequest is reported for requests canceled before a
+// response was written, following nginx's convention.
+const statusClientClosedRequest = 499
+
+// helloWorldHandler handles the API request and returns "Hello, World!"
+func (a *App) helloWorldHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	a.runPhase(ctx, "validate", func(ctx context.Context) {
+		// Nothing to validate for this endpoint; a real handler would check its
+		// input here.
+	})
+
+	var failed bool
+	var canceled error
+	a.runPhase(ctx, "process", func(ctx context.Context) {
+		if canceled = a.simulateWork(ctx); canceled != nil {
+			return
+		}
+
+		// Simulate a potential error
+		failed = rand.Float64() < a.cfg.simulatedErrorRate
+	})
+
+	if canceled != nil {
+		span.AddEvent("request canceled", trace.WithAttributes(
+			attribute.String("reason", canceled.Error()),
+		))
+		a.canceledCounter.Add(ctx, 1, withMetricAttributes(requestMetricAttributes(r)...))
+		w.WriteHeader(statusClientClosedRequest)
+		return
+	}
+
+	if failed {
+		a.runPhase(ctx, "respond", func(ctx context.Context) {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		})
+		err := errors.New("simulated internal server error")
+		a.errorCounter.Add(ctx, 1, withMetricAttributes(requestMetricAttributes(r)...))
+		recordError(span, err)
+		span.SetStatus(codes.Error, "internal server error")
+		logger.ErrorContext(ctx, "request failed", "error", err)
 
 		// HTTP request failed
-		span.SetAttributes(
-			attribute.Bool("helloWorldHandler.error", true),
-			attribute.Int64("http.status", http.StatusInternalServerError),
-		)
+		span.SetAttributes(attribute.Bool("helloWorldHandler.error", true))
 
 		return
 	}
 
 	// HTTP request successful
-	span.SetAttributes(
-		attribute.Bool("helloWorldHandler.error", false),
-		attribute.Int64("http.status", http.StatusOK),
-	)
+	span.SetAttributes(attribute.Bool("helloWorldHandler.error", false))
+	span.SetStatus(codes.Ok, "")
 
 	// Respond with "Hello, World!"
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write([]byte("Hello, World!"))
+	a.runPhase(ctx, "respond", func(ctx context.Context) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Hello, World!"))
+	})
 }
 
-func cartAddHandler(w http.ResponseWriter, r *http.Request) {
-	cartCount = cartCount + 1
-	itemGauge.Record(r.Context(), cartCount)
+// recordCartGauge records the cart size on the gauge and adds a matching event
+// to the handler span, so the recording shows up in the request's timeline.
+func (a *App) recordCartGauge(ctx context.Context, span trace.Span, count int64) {
+	a.itemGauge.Record(ctx, count)
+	span.AddEvent("gauge.recorded", trace.WithAttributes(
+		attribute.Int64("api.cart.items", count),
+	))
+}
 
-	_, span := tracer.Start(r.Context(), "cartAddHandler")
-	defer span.End()
-	// Add the current cartCount as an attribute
+func (a *App) cartAddHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	// Mutating endpoints only accept POST
+	span.AddEvent("validating cart")
+	if !a.requireMethod(w, r, span, http.MethodPost) {
+		return
+	}
+
+	user := cartUser(r)
+	cartCount, added := a.addCartItem(user)
+	if !added {
+		a.cartRejectedAddsCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "cart_full")))
+		span.AddEvent("cart full", trace.WithAttributes(attribute.Int64("api.cart.items", cartCount)))
+		span.SetAttributes(
+			attribute.String("cart.user", user),
+			attribute.Bool("cartAddHandler.rejected", true),
+		)
+		http.Error(w, fmt.Sprintf("Cart is full (%d items).", cartCount), http.StatusConflict)
+		return
+	}
+	a.cartSizeDeltaCounter.Add(ctx, 1)
+	span.AddEvent("item added", trace.WithAttributes(attribute.Int64("api.cart.items", cartCount)))
+	a.recordCartGauge(ctx, span, cartCount)
+
+	// Add the user and their current cartCount as attributes
 	span.SetAttributes(
+		attribute.String("cart.user", user),
 		attribute.Int64("cartAddHandler.cartCount", cartCount),
 	)
+	span.SetStatus(codes.Ok, "")
 
 	message := fmt.Sprintf("Item added to cart. Number of items in cart: %d.", cartCount)
 	w.WriteHeader(http.StatusOK)
 	_, _ = w.Write([]byte(message))
 }
 
-func cartRemoveHandler(w http.ResponseWriter, r *http.Request) {
-	if cartCount != 0 {
-		cartCount = cartCount - 1
+func (a *App) cartRemoveHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	// Mutating endpoints only accept POST
+	span.AddEvent("validating cart")
+	if !a.requireMethod(w, r, span, http.MethodPost) {
+		return
 	}
-	itemGauge.Record(r.Context(), cartCount)
 
-	_, span := tracer.Start(r.Context(), "cartRemoveHandler")
-	defer span.End()
-	// Add the current cartCount as an attribute
+	user := cartUser(r)
+	cartCount, removed := a.removeCartItem(user)
+	if removed {
+		a.cartSizeDeltaCounter.Add(ctx, -1)
+		span.AddEvent("item removed", trace.WithAttributes(attribute.Int64("api.cart.items", cartCount)))
+	} else {
+		span.AddEvent("cart empty")
+	}
+	a.recordCartGauge(ctx, span, cartCount)
+
+	// Add the user and their current cartCount as attributes
 	span.SetAttributes(
+		attribute.String("cart.user", user),
 		attribute.Int64("cartRemoveHandler.cartCount", cartCount),
 	)
+	span.SetStatus(codes.Ok, "")
 
 	message := fmt.Sprintf("Item removed from cart. Number of items in cart: %d.", cartCount)
 	w.WriteHeader(http.StatusOK)
 	_, _ = w.Write([]byte(message))
 }
+
+func (a *App) cartClearHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	// Mutating endpoints only accept POST
+	span.AddEvent("validating cart")
+	if !a.requireMethod(w, r, span, http.MethodPost) {
+		return
+	}
+
+	user := cartUser(r)
+	cleared := a.clearCart(user)
+	a.cartSizeDeltaCounter.Add(ctx, -cleared)
+	span.AddEvent("cart cleared", trace.WithAttributes(attribute.Int64("cleared", cleared)))
+	a.recordCartGauge(ctx, span, 0)
+
+	span.SetAttributes(
+		attribute.String("cart.user", user),
+		attribute.Int64("cartClearHandler.clearedCount", cleared),
+	)
+	span.SetStatus(codes.Ok, "")
+
+	message := fmt.Sprintf("Cart cleared. Removed %d items.", cleared)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(message))
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// newTestApp returns an App configured by cfg whose spans go to the returned
+// recorder and whose metrics are collected with the returned reader.
+func newTestApp(t *testing.T, cfg config) (*App, *tracetest.SpanRecorder, *sdkmetric.ManualReader) {
+	t.Helper()
+
+	spans := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	t.Cleanup(func() {
+		_ = tp.Shutdown(context.Background())
+		_ = mp.Shutdown(context.Background())
+	})
+
+	app, err := NewApp(cfg, tp.Tracer("test"), mp.Meter("test"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return app, spans, reader
+}
+
+// serve sends a request through handler and returns the recorded response.
+func serve(handler http.Handler, method, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+	return rec
+}
+
+func TestHelloWorld(t *testing.T) {
+	app, spans, _ := newTestApp(t, config{})
+	server, _, err := NewServer(app, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := serve(server.Handler, http.MethodGet, "/")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if body := rec.Body.String(); body != "Hello, World!" {
+		t.Errorf("body = %q, want %q", body, "Hello, World!")
+	}
+
+	attrs := attribute.NewSet(endedSpan(t, spans, "/").Attributes()...)
+	if value, ok := attrs.Value("helloWorldHandler.error"); !ok || value.AsBool() {
+		t.Errorf("span attributes %v do not have helloWorldHandler.error=false", attrs.ToSlice())
+	}
+}
+
+func TestHelloWorldError(t *testing.T) {
+	app, spans, reader := newTestApp(t, config{simulatedErrorRate: 1})
+	server, _, err := NewServer(app, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := serve(server.Handler, http.MethodGet, "/")
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+
+	if errors := collectInt64Sum(t, reader, "api.request.error_counter"); errors != 1 {
+		t.Errorf("api.request.error_counter = %d, want 1", errors)
+	}
+	attrs := attribute.NewSet(endedSpan(t, spans, "/").Attributes()...)
+	if value, ok := attrs.Value("helloWorldHandler.error"); !ok || !value.AsBool() {
+		t.Errorf("span attributes %v do not have helloWorldHandler.error=true", attrs.ToSlice())
+	}
+}
+
+func TestCartAddRemove(t *testing.T) {
+	app, _, reader := newTestApp(t, config{})
+	server, _, err := NewServer(app, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, target := range []string{"/cart/add", "/cart/remove"} {
+		if rec := serve(server.Handler, http.MethodPost, target); rec.Code != http.StatusOK {
+			t.Fatalf("POST %s: status = %d, want %d", target, rec.Code, http.StatusOK)
+		}
+	}
+
+	if items := collectInt64Gauge(t, reader, "api.cart.items"); items != 0 {
+		t.Errorf("api.cart.items = %d, want 0", items)
+	}
+}
+
+func TestHandlerPhases(t *testing.T) {
+	app, spans, reader := newTestApp(t, config{})
+	app.otelMiddleware(app.helloWorldHandler, "helloWorldHandler")(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	request := endedSpan(t, spans, "helloWorldHandler")
+	phases := []string{"validate", "process", "respond"}
+	for _, phase := range phases {
+		span := endedSpan(t, spans, phase)
+		if span.Parent().SpanID() != request.SpanContext().SpanID() {
+			t.Errorf("%s span is not a child of the request span", phase)
+		}
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]uint64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "app.handler.phase_seconds" {
+				continue
+			}
+			for _, dp := range m.Data.(metricdata.Histogram[float64]).DataPoints {
+				phase, _ := dp.Attributes.Value("phase")
+				counts[phase.AsString()] += dp.Count
+			}
+		}
+	}
+	for _, phase := range phases {
+		if counts[phase] != 1 {
+			t.Errorf("%s phase has %d measurements, want 1", phase, counts[phase])
+		}
+	}
+}
+
+func TestHandlerSpanStatus(t *testing.T) {
+	app, spans, _ := newTestApp(t, config{simulatedErrorRate: 0.5})
+
+	// The hello world handler fails at random, so make enough requests to
+	// see both outcomes
+	var want []codes.Code
+	for i := 0; i < 50; i++ {
+		w := httptest.NewRecorder()
+		app.otelMiddleware(app.helloWorldHandler, "helloWorldHandler")(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code == http.StatusInternalServerError {
+			want = append(want, codes.Error)
+		} else {
+			want = append(want, codes.Ok)
+		}
+	}
+	app.otelMiddleware(app.cartAddHandler, "cartAddHandler")(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/cart/add", nil))
+	app.otelMiddleware(app.cartRemoveHandler, "cartRemoveHandler")(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/cart/remove", nil))
+	want = append(want, codes.Ok, codes.Ok)
+
+	var got []codes.Code
+	for _, span := range spans.Ended() {
+		switch span.Name() {
+		case "helloWorldHandler", "cartAddHandler", "cartRemoveHandler":
+			got = append(got, span.Status().Code)
+		}
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("span statuses = %v, want %v", got, want)
+	}
+}
+
+func TestCartGaugeEvent(t *testing.T) {
+	app, spans, _ := newTestApp(t, config{})
+
+	app.otelMiddleware(app.cartAddHandler, "cartAddHandler")(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/cart/add", nil))
+
+	for _, event := range endedSpan(t, spans, "cartAddHandler").Events() {
+		if event.Name != "gauge.recorded" {
+			continue
+		}
+		attrs := attribute.NewSet(event.Attributes...)
+		if value, ok := attrs.Value("api.cart.items"); !ok || value.AsInt64() != 1 {
+			t.Errorf("gauge.recorded attributes %v do not have api.cart.items=1", attrs.ToSlice())
+		}
+		return
+	}
+	t.Error("no gauge.recorded event was added")
+}
+
+func TestOTLPTimeoutAppliedToExporter(t *testing.T) {
+	// The collector accepts connections but never answers, so only the
+	// timeout ends the export
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	t.Setenv("OTEL_EXPORTER_OTLP_TIMEOUT", "10000")
+	// The per-signal variable wins over the generic one
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_TIMEOUT", "50")
+	ctx := context.Background()
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	exporter, err := newTraceExporter(ctx, otlpTarget{endpoint: lis.Addr().String(), conn: conn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = exporter.Shutdown(ctx) }()
+
+	start := time.Now()
+	err = exporter.ExportSpans(ctx, tracetest.SpanStubs{{Name: "span"}}.Snapshots())
+	if err == nil {
+		t.Fatal("export to an unresponsive collector succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("export gave up after %s, want about 50ms", elapsed)
+	}
+}
+
+func TestLatencySumCounters(t *testing.T) {
+	t.Setenv("LATENCY_SUM_COUNTERS", "true")
+	app, _, reader := newTestApp(t, config{})
+
+	const requests = 3
+	for range requests {
+		app.recordLatencyHistogram(context.Background(), time.Now().Add(-time.Millisecond))
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	var sum float64
+	var count int64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch m.Name {
+			case "api.request.latency.sum":
+				for _, dp := range m.Data.(metricdata.Sum[float64]).DataPoints {
+					sum += dp.Value
+				}
+			case "api.request.latency.count":
+				for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+					count += dp.Value
+				}
+			}
+		}
+	}
+	if count != requests {
+		t.Errorf("api.request.latency.count = %d, want %d", count, requests)
+	}
+	// Every request took at least a millisecond
+	if least := requests * time.Millisecond.Seconds(); sum < least {
+		t.Errorf("api.request.latency.sum = %vs, want at least %vs", sum, least)
+	}
+}
+
+func TestLatencyExemplarLinksTrace(t *testing.T) {
+	app, _, reader := newTestApp(t, config{})
+
+	ctx, span := app.tracer.Start(context.Background(), "request")
+	app.recordLatencyHistogram(ctx, time.Now())
+	span.End()
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	traceID := span.SpanContext().TraceID()
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "api.request.latency_seconds" {
+				continue
+			}
+			for _, dp := range m.Data.(metricdata.Histogram[float64]).DataPoints {
+				for _, exemplar := range dp.Exemplars {
+					if trace.TraceID(exemplar.TraceID) == traceID {
+						return
+					}
+				}
+			}
+		}
+	}
+	t.Errorf("no api.request.latency_seconds exemplar links to trace %s", traceID)
+}
+
+func TestMemoryPressure(t *testing.T) {
+	tests := []struct {
+		name      string
+		heapInuse uint64
+		sys       uint64
+		want      int64
+	}{
+		{name: "no memory obtained", heapInuse: 0, sys: 0, want: 0},
+		{name: "below threshold", heapInuse: 50, sys: 100, want: 0},
+		{name: "at threshold", heapInuse: 90, sys: 100, want: 0},
+		{name: "above threshold", heapInuse: 95, sys: 100, want: 1},
+	}
+	for _, tt := range tests {
+		memStats := &runtime.MemStats{HeapInuse: tt.heapInuse, Sys: tt.sys}
+		if got := memoryPressure(memStats, 0.9); got != tt.want {
+			t.Errorf("%s: memoryPressure = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSimulatedLatency(t *testing.T) {
+	app, _, _ := newTestApp(t, config{simulatedLatency: 20 * time.Millisecond})
+
+	start := time.Now()
+	if err := app.simulateWork(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < app.cfg.simulatedLatency/2 {
+		t.Errorf("simulated work took %s, want at least %s", elapsed, app.cfg.simulatedLatency/2)
+	}
+}
+
+func TestCanceledRequest(t *testing.T) {
+	app, spans, reader := newTestApp(t, config{simulatedLatency: time.Minute})
+
+	// The client is gone before the work would finish
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rec := httptest.NewRecorder()
+	app.otelMiddleware(app.helloWorldHandler, "helloWorldHandler")(rec, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+
+	if rec.Code != statusClientClosedRequest {
+		t.Errorf("status = %d, want %d", rec.Code, statusClientClosedRequest)
+	}
+	if n := collectInt64Sum(t, reader, "api.request.canceled"); n != 1 {
+		t.Errorf("api.request.canceled = %d, want 1", n)
+	}
+	events := endedSpan(t, spans, "helloWorldHandler").Events()
+	if !slices.ContainsFunc(events, func(e sdktrace.Event) bool { return e.Name == "request canceled" }) {
+		t.Error("no request canceled event was added")
+	}
+}
+
+func TestLoadSimulatedErrorRate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  float64
+	}{
+		{"", 0.5},
+		{"0.1", 0.1},
+		{"0", 0},
+		{"-1", 0},
+		{"2", 1},
+		{"often", 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("SIMULATED_ERROR_RATE", tt.value)
+			if got := loadSimulatedErrorRate(); got != tt.want {
+				t.Errorf("loadSimulatedErrorRate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBatchSpanProcessorOptions(t *testing.T) {
+	t.Setenv("OTEL_BSP_MAX_QUEUE_SIZE", "100")
+	t.Setenv("OTEL_BSP_MAX_EXPORT_BATCH_SIZE", "10")
+	t.Setenv("OTEL_BSP_SCHEDULE_DELAY", "250")
+
+	// Unset variables leave the SDK defaults in place
+	o := sdktrace.BatchSpanProcessorOptions{MaxQueueSize: 2048, MaxExportBatchSize: 512, BatchTimeout: 5 * time.Second}
+	for _, opt := range batchSpanProcessorOptions() {
+		opt(&o)
+	}
+	want := sdktrace.BatchSpanProcessorOptions{MaxQueueSize: 100, MaxExportBatchSize: 10, BatchTimeout: 250 * time.Millisecond}
+	if o != want {
+		t.Errorf("options = %+v, want %+v", o, want)
+	}
+
+	t.Setenv("OTEL_BSP_MAX_QUEUE_SIZE", "")
+	t.Setenv("OTEL_BSP_MAX_EXPORT_BATCH_SIZE", "")
+	t.Setenv("OTEL_BSP_SCHEDULE_DELAY", "")
+	if opts := batchSpanProcessorOptions(); len(opts) != 0 {
+		t.Errorf("got %d options with nothing set, want 0", len(opts))
+	}
+}
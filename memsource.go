@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// memorySource reports the memory usage behind process.allocated_memory.
+// Implementations let the value come from somewhere other than the Go
+// runtime, such as the container's cgroup.
+type memorySource interface {
+	// AllocatedBytes returns the memory currently in use, in bytes.
+	AllocatedBytes() (uint64, error)
+}
+
+// memStatsSource reports the heap allocated by the Go runtime.
+type memStatsSource struct{}
+
+func (memStatsSource) AllocatedBytes() (uint64, error) {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	return memStats.Alloc, nil
+}
+
+// cgroupV2Source reports the memory charged to the container's cgroup, which
+// includes memory the Go runtime doesn't account for.
+type cgroupV2Source struct {
+	// path is the cgroup v2 memory.current file.
+	path string
+}
+
+func (s cgroupV2Source) AllocatedBytes() (uint64, error) {
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read cgroup memory usage: %w", err)
+	}
+
+	n, err := strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse cgroup memory usage from %s: %w", s.path, err)
+	}
+
+	return n, nil
+}
+
+// newMemorySource returns the memory source named by MEMORY_SOURCE:
+// "memstats" (the default) or "cgroup".
+func newMemorySource(name string) (memorySource, error) {
+	switch name {
+	case "", "memstats":
+		return memStatsSource{}, nil
+	case "cgroup":
+		return cgroupV2Source{path: "/sys/fs/cgroup/memory.current"}, nil
+	default:
+		return nil, fmt.Errorf("unknown memory source %q: expected memstats or cgroup", name)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// fakeMemorySource reports a fixed amount of allocated memory.
+type fakeMemorySource uint64
+
+func (s fakeMemorySource) AllocatedBytes() (uint64, error) { return uint64(s), nil }
+
+// collectFloat64Gauge collects reader and returns the value of the named
+// gauge.
+func collectFloat64Gauge(t *testing.T, reader sdkmetric.Reader, name string) float64 {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+			gauge, ok := m.Data.(metricdata.Gauge[float64])
+			if !ok {
+				t.Fatalf("%s is a %T, want a float64 gauge", name, m.Data)
+			}
+			return gauge.DataPoints[0].Value
+		}
+	}
+	t.Fatalf("no %s metric was collected", name)
+	return 0
+}
+
+func TestMemorySource(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer func() { _ = mp.Shutdown(context.Background()) }()
+
+	_, err := mp.Meter("test").Float64ObservableGauge("process.allocated_memory",
+		metric.WithFloat64Callback(allocatedMemoryCallback(fakeMemorySource(3<<20))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := collectFloat64Gauge(t, reader, "process.allocated_memory"); got != 3 {
+		t.Errorf("process.allocated_memory = %v, want 3", got)
+	}
+}
+
+func TestGCCPUFraction(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer func() { _ = mp.Shutdown(context.Background()) }()
+
+	if err := registerRuntimeMetrics(mp.Meter("test"), memStatsSource{}); err != nil {
+		t.Fatal(err)
+	}
+	runtime.GC()
+	if got := collectFloat64Gauge(t, reader, "process.runtime.gc.cpu_fraction"); got < 0 || got > 1 {
+		t.Errorf("process.runtime.gc.cpu_fraction = %v, want a value in [0, 1]", got)
+	}
+}
+
+func TestGoRuntimeGauges(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer func() { _ = mp.Shutdown(context.Background()) }()
+
+	if err := registerRuntimeMetrics(mp.Meter("test"), memStatsSource{}); err != nil {
+		t.Fatal(err)
+	}
+	// Guarantee at least one GC pause to report
+	runtime.GC()
+
+	for _, name := range []string{
+		"process.runtime.go.goroutines",
+		"process.runtime.go.gc.pause_ns",
+		"process.runtime.go.heap.objects",
+	} {
+		if got := collectInt64Gauge(t, reader, name); got <= 0 {
+			t.Errorf("%s = %d, want a positive value", name, got)
+		}
+	}
+}
+
+func TestUnknownMemorySource(t *testing.T) {
+	if _, err := newMemorySource("swap"); err == nil {
+		t.Error("newMemorySource accepted an unknown source")
+	}
+}
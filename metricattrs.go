@@ -0,0 +1,23 @@
+package main
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// metricAttributeLimit caps the number of attributes attached to a single
+// measurement, as a defense against cardinality growth. Zero means no limit.
+var metricAttributeLimit = envInt("METRIC_ATTRIBUTE_LIMIT", 0)
+
+// withMetricAttributes is metric.WithAttributes with the attributes beyond
+// metricAttributeLimit dropped. Call sites pass their most important
+// attributes first. On canary instances deployment.canary=true comes first.
+func withMetricAttributes(attrs ...attribute.KeyValue) metric.MeasurementOption {
+	if canary {
+		attrs = append([]attribute.KeyValue{canaryAttribute}, attrs...)
+	}
+	if metricAttributeLimit > 0 && len(attrs) > metricAttributeLimit {
+		attrs = attrs[:metricAttributeLimit]
+	}
+	return metric.WithAttributes(attrs...)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestMetricAttributeLimit(t *testing.T) {
+	defer func(orig int) { metricAttributeLimit = orig }(metricAttributeLimit)
+	metricAttributeLimit = 2
+	ctx := context.Background()
+
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer func() { _ = mp.Shutdown(ctx) }()
+	counter, err := mp.Meter("test").Int64Counter("requests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter.Add(ctx, 1, withMetricAttributes(
+		attribute.String("http.route", "/"),
+		attribute.Int("http.status_code", 200),
+		attribute.String("extra", "dropped"),
+	))
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	attrs := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64]).DataPoints[0].Attributes
+	if attrs.Len() != 2 {
+		t.Errorf("data point has %d attributes, want 2", attrs.Len())
+	}
+	for _, key := range []attribute.Key{"http.route", "http.status_code"} {
+		if !attrs.HasValue(key) {
+			t.Errorf("data point attributes %v have no %s", attrs.ToSlice(), key)
+		}
+	}
+	if attrs.HasValue("extra") {
+		t.Errorf("data point attributes %v kept the attribute beyond the limit", attrs.ToSlice())
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// withMiddleware applies the middleware shared by all API routes.
+func (a *App) withMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	handler := extractTraceContext(a.withTraceContextValidation(withCorrelation(withPriority(limitRequestBody(a.countRequestBodyBytes(next))))))
+	return func(w http.ResponseWriter, r *http.Request) {
+		markFirstRequest()
+		handler(w, r)
+	}
+}
+
+// maxRequestBodyBytes caps how much of a request body handlers may read.
+var maxRequestBodyBytes = int64(envInt("MAX_REQUEST_BODY_BYTES", 1<<20))
+
+// limitRequestBody protects memory by failing reads past maxRequestBodyBytes.
+// Handlers report the failure with bodyReadError.
+func limitRequestBody(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)
+		}
+		next(w, r)
+	}
+}
+
+// bodyReadError responds to a failed request body read: 413 when the body is
+// over the limit, counted in bodyTooLargeCounter, and 400 otherwise.
+func (a *App) bodyReadError(w http.ResponseWriter, r *http.Request, span trace.Span, err error) {
+	recordError(span, err)
+
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		span.SetStatus(codes.Error, "request body too large")
+		a.bodyTooLargeCounter.Add(r.Context(), 1)
+		http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	span.SetStatus(codes.Error, "failed to read request body")
+	http.Error(w, "Bad Request", http.StatusBadRequest)
+}
+
+// countingReader wraps a request body and adds every byte actually read to
+// counter. Unlike Content-Length this also covers chunked bodies.
+type countingReader struct {
+	ctx     context.Context
+	body    io.ReadCloser
+	counter metric.Int64Counter
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.body.Read(p)
+	if n > 0 {
+		c.counter.Add(c.ctx, int64(n))
+	}
+	return n, err
+}
+
+func (c *countingReader) Close() error {
+	return c.body.Close()
+}
+
+// countRequestBodyBytes wraps r.Body so that bytes consumed by the handler are
+// counted in bodyBytesCounter.
+func (a *App) countRequestBodyBytes(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = &countingReader{ctx: r.Context(), body: r.Body, counter: a.bodyBytesCounter}
+		}
+		next(w, r)
+	}
+}
+
+// requireMethod rejects a request whose method isn't method with a 405 and an
+// Allow header, marking span as failed and counting the rejection. It reports
+// whether the handler may proceed.
+func (a *App) requireMethod(w http.ResponseWriter, r *http.Request, span trace.Span, method string) bool {
+	if r.Method == method {
+		return true
+	}
+
+	w.Header().Set("Allow", method)
+	http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+
+	span.SetStatus(codes.Error, "method not allowed")
+	span.SetAttributes(
+		attribute.String("http.method", r.Method),
+		attribute.Int64("http.status_code", http.StatusMethodNotAllowed),
+	)
+	a.methodNotAllowedCounter.Add(r.Context(), 1, metric.WithAttributes(
+		attribute.String("http.method", r.Method),
+	))
+
+	return false
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCountRequestBodyBytes(t *testing.T) {
+	app, _, reader := newTestApp(t, config{})
+	handler := app.countRequestBodyBytes(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+	})
+
+	// A body of unknown length is sent chunked, with no Content-Length
+	const body = "a body sent in several chunks"
+	r := httptest.NewRequest(http.MethodPost, "/", io.MultiReader(strings.NewReader(body[:10]), strings.NewReader(body[10:])))
+	r.ContentLength = -1
+	r.TransferEncoding = []string{"chunked"}
+	handler(httptest.NewRecorder(), r)
+
+	if n := collectInt64Sum(t, reader, "http.server.request.body.bytes_read"); n != int64(len(body)) {
+		t.Errorf("bytes read = %d, want %d", n, len(body))
+	}
+}
+
+func TestRequireMethod(t *testing.T) {
+	app, _, reader := newTestApp(t, config{})
+
+	rec := httptest.NewRecorder()
+	app.cartAddHandler(rec, httptest.NewRequest(http.MethodGet, "/cart/add", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+	if allow := rec.Header().Get("Allow"); allow != http.MethodPost {
+		t.Errorf("GET: Allow = %q, want %q", allow, http.MethodPost)
+	}
+	rec = httptest.NewRecorder()
+	app.cartAddHandler(rec, httptest.NewRequest(http.MethodPost, "/cart/add", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("POST: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	if n := collectInt64Sum(t, reader, "api.request.method_not_allowed"); n != 1 {
+		t.Errorf("api.request.method_not_allowed = %d, want 1", n)
+	}
+}
+
+func TestRequestBodyTooLarge(t *testing.T) {
+	app, _, reader := newTestApp(t, config{})
+	defer func(orig int64) { maxRequestBodyBytes = orig }(maxRequestBodyBytes)
+	maxRequestBodyBytes = 16
+
+	rec := httptest.NewRecorder()
+	app.withMiddleware(app.correlateHandler)(rec, httptest.NewRequest(http.MethodPost, "/correlate", strings.NewReader(strings.Repeat("x", 100))))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+	if n := collectInt64Sum(t, reader, "api.request.body_too_large"); n != 1 {
+		t.Errorf("api.request.body_too_large = %d, want 1", n)
+	}
+}
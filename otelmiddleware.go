@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// responseWriter records the status code written by a handler.
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// statusCode returns the status code sent, which is 200 when the handler
+// never set one explicitly.
+func (w *responseWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// requestMetricAttributes returns the attributes request metrics are broken
+// down by.
+func requestMetricAttributes(r *http.Request) []attribute.KeyValue {
+	return append([]attribute.KeyValue{routeAttribute(r)}, syntheticAttributes(r)...)
+}
+
+// otelMiddleware traces next in a server span called name, which handlers get
+// from the request context, and counts requests and records their latency by
+// status code.
+// Spans the handler starts itself become children of it.
+func (a *App) otelMiddleware(next http.HandlerFunc, name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := a.tracer.Start(r.Context(), name, trace.WithSpanKind(trace.SpanKindServer))
+		defer span.End()
+		span.SetAttributes(httpAttributes(r)...)
+		span.SetAttributes(syntheticAttributes(r)...)
+		span.SetAttributes(baggageAttributes(ctx)...)
+
+		start := time.Now()
+		rw := &responseWriter{ResponseWriter: w}
+		a.recoverPanic(next)(rw, r.WithContext(ctx))
+
+		status := rw.statusCode()
+		span.SetAttributes(attribute.Int("http.status_code", status))
+
+		// Total calls, the denominator for api.request.error_counter
+		a.requestCounter.Add(ctx, 1, withMetricAttributes(append([]attribute.KeyValue{
+			routeAttribute(r),
+			attribute.Int("http.status_code", status),
+		}, syntheticAttributes(r)...)...))
+
+		// Separate slow failures from slow successes. As for server spans, only
+		// 5xx responses count as errors.
+		a.recordLatencyHistogram(ctx, start, append([]attribute.KeyValue{
+			routeAttribute(r),
+			attribute.Int("http.status_code", status),
+			attribute.Bool("error", status >= http.StatusInternalServerError),
+		}, syntheticAttributes(r)...)...)
+	}
+}
+
+// recoverPanic turns a panic in next into a 500 response, recorded as an error
+// on the request's span and in errorCounter. The panic is logged rather than
+// re-raised so the middleware above can still report the request.
+func (a *App) recoverPanic(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				// Deliberate abort of the response, which net/http handles
+				panic(p)
+			}
+
+			ctx := r.Context()
+			err := fmt.Errorf("panic: %v", p)
+			span := trace.SpanFromContext(ctx)
+			recordError(span, err)
+			span.SetStatus(codes.Error, "handler panicked")
+			a.errorCounter.Add(ctx, 1, withMetricAttributes(requestMetricAttributes(r)...))
+			logger.ErrorContext(ctx, "handler panicked", "error", err, "stack", string(debug.Stack()))
+
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}()
+		next(w, r)
+	}
+}
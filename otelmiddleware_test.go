@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestOtelMiddleware(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		status  int
+		failed  bool
+	}{
+		{
+			name:    "implicit status",
+			handler: func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte("ok")) },
+			status:  http.StatusOK,
+		},
+		{
+			name:    "explicit status",
+			handler: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusTeapot) },
+			status:  http.StatusTeapot,
+		},
+		{
+			name:    "server error",
+			handler: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusServiceUnavailable) },
+			status:  http.StatusServiceUnavailable,
+			failed:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, spans, reader := newTestApp(t, config{})
+
+			var handlerSpan trace.SpanContext
+			handler := app.otelMiddleware(func(w http.ResponseWriter, r *http.Request) {
+				handlerSpan = trace.SpanContextFromContext(r.Context())
+				tt.handler(w, r)
+			}, "handler")
+			handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+			span := endedSpan(t, spans, "handler")
+			if !handlerSpan.Equal(span.SpanContext()) {
+				t.Error("the handler did not get the middleware's span from its context")
+			}
+			if span.SpanKind() != trace.SpanKindServer {
+				t.Errorf("span kind = %v, want %v", span.SpanKind(), trace.SpanKindServer)
+			}
+			attrs := attribute.NewSet(span.Attributes()...)
+			if got, _ := attrs.Value("http.status_code"); got.AsInt64() != int64(tt.status) {
+				t.Errorf("http.status_code = %v, want %d", got.Emit(), tt.status)
+			}
+
+			var rm metricdata.ResourceMetrics
+			if err := reader.Collect(context.Background(), &rm); err != nil {
+				t.Fatal(err)
+			}
+			for _, sm := range rm.ScopeMetrics {
+				for _, m := range sm.Metrics {
+					if m.Name != "api.request.latency_seconds" {
+						continue
+					}
+					dps := m.Data.(metricdata.Histogram[float64]).DataPoints
+					if len(dps) != 1 || dps[0].Count != 1 {
+						t.Fatalf("latency data points = %+v, want a single measurement", dps)
+					}
+					if got, _ := dps[0].Attributes.Value("http.status_code"); got.AsInt64() != int64(tt.status) {
+						t.Errorf("latency http.status_code = %v, want %d", got.Emit(), tt.status)
+					}
+					if got, _ := dps[0].Attributes.Value("error"); got.AsBool() != tt.failed {
+						t.Errorf("latency error = %v, want %t", got.Emit(), tt.failed)
+					}
+					return
+				}
+			}
+			t.Error("no latency was recorded")
+		})
+	}
+}
+
+func TestOtelMiddlewareRecoversPanic(t *testing.T) {
+	app, spans, reader := newTestApp(t, config{})
+
+	rec := httptest.NewRecorder()
+	app.otelMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}, "handler")(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	span := endedSpan(t, spans, "handler")
+	if span.Status().Code != codes.Error {
+		t.Errorf("span status = %v, want %v", span.Status().Code, codes.Error)
+	}
+	if len(span.Events()) == 0 || span.Events()[0].Name != "exception" {
+		t.Error("the panic was not recorded on the span")
+	}
+	if errors := collectInt64Sum(t, reader, "api.request.error_counter"); errors != 1 {
+		t.Errorf("api.request.error_counter = %d, want 1", errors)
+	}
+}
+
+func TestOtelMiddlewareRepanicsOnAbort(t *testing.T) {
+	app, spans, _ := newTestApp(t, config{})
+
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler", p)
+		}
+		endedSpan(t, spans, "handler")
+	}()
+	app.otelMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}, "handler")(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+)
+
+// pprofEnabled exposes the runtime profiler under /debug/pprof.
+var pprofEnabled = envBool("ENABLE_PPROF", false)
+
+// registerPprof adds the net/http/pprof handlers to mux. They are registered
+// directly so profiling requests aren't traced themselves.
+func registerPprof(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPprofRoutes(t *testing.T) {
+	defer func(orig bool) { pprofEnabled = orig }(pprofEnabled)
+
+	tests := []struct {
+		name      string
+		enabled   bool
+		adminAddr string
+		want      int
+	}{
+		{name: "enabled", enabled: true, want: http.StatusOK},
+		{name: "disabled", enabled: false, want: http.StatusNotFound},
+		{name: "on the admin server", enabled: true, adminAddr: ":9090", want: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pprofEnabled = tt.enabled
+			app, spans, _ := newTestApp(t, config{adminAddr: tt.adminAddr})
+			server, _, err := NewServer(app, "", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rec := httptest.NewRecorder()
+			server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if n := len(spans.Ended()); n != 0 {
+				t.Errorf("got %d spans for a debug route, want none", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync/atomic"
+
+	"google.golang.org/grpc/connectivity"
+)
+
+// draining is set once the server has been asked to drain. Readiness then
+// fails so load balancers stop routing new traffic, while in-flight and
+// straggling requests are still served.
+var draining atomic.Bool
+
+// readyHandler is the readiness probe.
+func readyHandler(w http.ResponseWriter, r *http.Request) {
+	if draining.Load() {
+		http.Error(w, "Draining", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("Ready"))
+}
+
+// collector is the collector reported by /healthz. main sets it before
+// serving.
+var collector otlpTarget
+
+// healthzHandler is the health check. It fails unless the collector
+// connection is usable, so traffic is only routed to instances whose telemetry
+// pipeline works. OTLP/HTTP has no long-lived connection to check, so a
+// collector reached over HTTP is reported as healthy.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	var state string
+	status := http.StatusServiceUnavailable
+	switch {
+	case collector.conn != nil:
+		connState := collector.conn.GetState()
+		state = connState.String()
+		if connState == connectivity.Ready || connState == connectivity.Idle {
+			status = http.StatusOK
+		}
+	case collector.endpoint != "":
+		state, status = "HTTP", http.StatusOK
+	case stdoutExport:
+		// No collector is involved
+		state, status = "STDOUT", http.StatusOK
+	default:
+		state = connectivity.Shutdown.String()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"collector": collector.endpoint,
+		"state":     state,
+	})
+}
+
+// drainHandler flips the readiness probe to not ready without exiting, so
+// orchestrators can drain the instance before sending SIGTERM.
+func drainHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !draining.Swap(true) {
+		log.Println("Draining: readiness probe now reports not ready")
+		markLifecycle("drain started")
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	_, _ = w.Write([]byte("Draining"))
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDrain(t *testing.T) {
+	app, _, _ := newTestApp(t, config{})
+	defer draining.Store(false)
+
+	steps := []struct {
+		method, target string
+		handler        http.HandlerFunc
+		want           int
+	}{
+		{method: http.MethodGet, target: "/ready", handler: readyHandler, want: http.StatusOK},
+		{method: http.MethodPost, target: "/drain", handler: drainHandler, want: http.StatusAccepted},
+		{method: http.MethodGet, target: "/ready", handler: readyHandler, want: http.StatusServiceUnavailable},
+		// Requests still in flight, or routed before the probe failed, are
+		// served
+		{method: http.MethodPost, target: "/cart/add", handler: app.cartAddHandler, want: http.StatusOK},
+	}
+	for _, step := range steps {
+		rec := httptest.NewRecorder()
+		step.handler(rec, httptest.NewRequest(step.method, step.target, nil))
+		if rec.Code != step.want {
+			t.Errorf("%s %s: status = %d, want %d", step.method, step.target, rec.Code, step.want)
+		}
+	}
+}
+
+func TestAdminServer(t *testing.T) {
+	app, _, _ := newTestApp(t, config{adminAddr: ":9090"})
+	useCollector(t, newTestTarget(t))
+
+	server, _, err := NewServer(app, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin := NewAdminServer(app, app.cfg.adminAddr)
+
+	for _, target := range []string{"/ready", "/healthz"} {
+		rec := httptest.NewRecorder()
+		admin.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("admin server %s: status = %d, want %d", target, rec.Code, http.StatusOK)
+		}
+	}
+	// The probes aren't registered on the API server, where the path is just
+	// an unknown route served by "/"
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	if rec.Body.String() == "Ready" {
+		t.Error("the API server answered the readiness probe")
+	}
+}
+
+// useCollector sets the collector reported by /healthz for the test.
+func useCollector(t *testing.T, target otlpTarget) {
+	orig := collector
+	t.Cleanup(func() { collector = orig })
+	collector = target
+}
+
+func TestHealthz(t *testing.T) {
+	tests := []struct {
+		name   string
+		target otlpTarget
+		stdout bool
+		want   int
+		state  string
+	}{
+		// grpc.NewClient doesn't connect until it is used
+		{name: "idle gRPC connection", target: newTestTarget(t), want: http.StatusOK, state: "IDLE"},
+		{name: "OTLP/HTTP", target: otlpTarget{endpoint: "localhost:4318"}, want: http.StatusOK, state: "HTTP"},
+		{name: "stdout", stdout: true, want: http.StatusOK, state: "STDOUT"},
+		{name: "no collector", want: http.StatusServiceUnavailable, state: "SHUTDOWN"},
+	}
+	defer func(orig bool) { stdoutExport = orig }(stdoutExport)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useCollector(t, tt.target)
+			stdoutExport = tt.stdout
+
+			rec := httptest.NewRecorder()
+			healthzHandler(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			var body map[string]string
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body["state"] != tt.state || body["collector"] != tt.target.endpoint {
+				t.Errorf("body = %v, want collector %q in state %s", body, tt.target.endpoint, tt.state)
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"context"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// noopProcessor implements sdktrace.SpanProcessor with methods that do
+// nothing. Processors that hold no state and only act when a span starts or
+// ends embed it and define just that method.
+type noopProcessor struct{}
+
+func (noopProcessor) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+func (noopProcessor) OnEnd(sdktrace.ReadOnlySpan) {}
+
+func (noopProcessor) Shutdown(context.Context) error { return nil }
+
+func (noopProcessor) ForceFlush(context.Context) error { return nil }
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/log/global"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// errProviderInstalled is returned when a provider is initialized twice.
+var errProviderInstalled = errors.New("provider already initialized")
+
+var (
+	providersMu             sync.Mutex
+	installedMeterProvider  *sdkmetric.MeterProvider
+	installedTracerProvider *sdktrace.TracerProvider
+	installedLoggerProvider *sdklog.LoggerProvider
+
+	// allowProviderReinit lets providers be initialized more than once, shutting
+	// down the previous one first. It is meant for tests; main leaves it off so
+	// that double initialization is reported instead of leaking providers.
+	allowProviderReinit = false
+)
+
+// installMeterProvider sets mp as the global meter provider.
+func installMeterProvider(ctx context.Context, mp *sdkmetric.MeterProvider) error {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	if installedMeterProvider != nil {
+		if !allowProviderReinit {
+			return fmt.Errorf("meter %w", errProviderInstalled)
+		}
+		if err := installedMeterProvider.Shutdown(ctx); err != nil {
+			return fmt.Errorf("failed to shutdown previous MeterProvider: %w", err)
+		}
+	}
+
+	installedMeterProvider = mp
+	otel.SetMeterProvider(mp)
+	return nil
+}
+
+// installTracerProvider sets tp as the global tracer provider.
+func installTracerProvider(ctx context.Context, tp *sdktrace.TracerProvider) error {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	if installedTracerProvider != nil {
+		if !allowProviderReinit {
+			return fmt.Errorf("tracer %w", errProviderInstalled)
+		}
+		if err := installedTracerProvider.Shutdown(ctx); err != nil {
+			return fmt.Errorf("failed to shutdown previous TracerProvider: %w", err)
+		}
+	}
+
+	installedTracerProvider = tp
+	otel.SetTracerProvider(tp)
+	return nil
+}
+
+// installLoggerProvider sets lp as the global logger provider.
+func installLoggerProvider(ctx context.Context, lp *sdklog.LoggerProvider) error {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	if installedLoggerProvider != nil {
+		if !allowProviderReinit {
+			return fmt.Errorf("logger %w", errProviderInstalled)
+		}
+		if err := installedLoggerProvider.Shutdown(ctx); err != nil {
+			return fmt.Errorf("failed to shutdown previous LoggerProvider: %w", err)
+		}
+	}
+
+	installedLoggerProvider = lp
+	global.SetLoggerProvider(lp)
+	return nil
+}
+
+// forceFlushProviders makes the installed providers export everything they
+// have buffered. Spans and logs are flushed before metrics, so that metrics
+// counting exported spans are up to date.
+func forceFlushProviders(ctx context.Context) error {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	var errs []error
+	if installedTracerProvider != nil {
+		errs = append(errs, installedTracerProvider.ForceFlush(ctx))
+	}
+	if installedLoggerProvider != nil {
+		errs = append(errs, installedLoggerProvider.ForceFlush(ctx))
+	}
+	if installedMeterProvider != nil {
+		errs = append(errs, installedMeterProvider.ForceFlush(ctx))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// resetProviders forgets the installed providers when the test ends.
+func resetProviders(t *testing.T) {
+	t.Cleanup(func() {
+		providersMu.Lock()
+		defer providersMu.Unlock()
+		installedMeterProvider = nil
+		installedTracerProvider = nil
+		installedLoggerProvider = nil
+		allowProviderReinit = false
+	})
+}
+
+func TestInstallProviderTwice(t *testing.T) {
+	resetProviders(t)
+	ctx := context.Background()
+
+	if err := installMeterProvider(ctx, sdkmetric.NewMeterProvider()); err != nil {
+		t.Fatal(err)
+	}
+	if err := installMeterProvider(ctx, sdkmetric.NewMeterProvider()); !errors.Is(err, errProviderInstalled) {
+		t.Errorf("second installMeterProvider = %v, want %v", err, errProviderInstalled)
+	}
+
+	if err := installTracerProvider(ctx, sdktrace.NewTracerProvider()); err != nil {
+		t.Fatal(err)
+	}
+	if err := installTracerProvider(ctx, sdktrace.NewTracerProvider()); !errors.Is(err, errProviderInstalled) {
+		t.Errorf("second installTracerProvider = %v, want %v", err, errProviderInstalled)
+	}
+
+	if err := installLoggerProvider(ctx, sdklog.NewLoggerProvider()); err != nil {
+		t.Fatal(err)
+	}
+	if err := installLoggerProvider(ctx, sdklog.NewLoggerProvider()); !errors.Is(err, errProviderInstalled) {
+		t.Errorf("second installLoggerProvider = %v, want %v", err, errProviderInstalled)
+	}
+}
+
+func TestReinitShutsDownPreviousProvider(t *testing.T) {
+	resetProviders(t)
+	allowProviderReinit = true
+	ctx := context.Background()
+
+	reader := sdkmetric.NewManualReader()
+	if err := installMeterProvider(ctx, sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))); err != nil {
+		t.Fatal(err)
+	}
+	if err := installMeterProvider(ctx, sdkmetric.NewMeterProvider()); err != nil {
+		t.Fatal(err)
+	}
+	if err := reader.Collect(ctx, &metricdata.ResourceMetrics{}); !errors.Is(err, sdkmetric.ErrReaderShutdown) {
+		t.Errorf("previous MeterProvider was not shut down: Collect = %v", err)
+	}
+
+	first := sdktrace.NewTracerProvider()
+	if err := installTracerProvider(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	if err := installTracerProvider(ctx, sdktrace.NewTracerProvider()); err != nil {
+		t.Fatal(err)
+	}
+	// A shut down provider only hands out non-recording spans
+	_, span := first.Tracer("test").Start(ctx, "after reinit")
+	if span.IsRecording() {
+		t.Error("previous TracerProvider was not shut down")
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// serviceVersion is reported as service.version unless SERVICE_VERSION is set.
+// Release builds set it to the git SHA with
+//
+//	go build -ldflags "-X main.serviceVersion=$(git rev-parse --short HEAD)"
+var serviceVersion = "dev"
+
+// newResource builds the resource shared by all signals, reporting
+// serviceName. When base is non-nil it is merged on top of the defaults, so
+// attributes supplied by the deployer or an embedding application win on
+// conflict.
+func newResource(ctx context.Context, serviceName string, base *resource.Resource) (*resource.Resource, error) {
+	res, err := resource.New(ctx,
+		resource.WithAttributes(
+			// The service name used to display traces in backends
+			attribute.String("service.name", serviceName),
+			attribute.String("library.language", "go"),
+			attribute.String("service.version", envString("SERVICE_VERSION", serviceVersion)),
+			// CPU capacity, to tell apart latency differences between hosts
+			attribute.Int("host.cpu.count", runtime.NumCPU()),
+			attribute.Int("process.runtime.gomaxprocs", runtime.GOMAXPROCS(0)),
+		),
+		resource.WithAttributes(deploymentAttributes()...),
+		resource.WithHost(),
+		resource.WithProcess(),
+		resource.WithOS(),
+		resource.WithContainer(),
+	)
+	// Some detectors fail outside their environment, e.g. the container
+	// detector on bare metal; keep whatever the others found.
+	if errors.Is(err, resource.ErrPartialResource) {
+		log.Printf("Some resource attributes could not be detected: %v", err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if base == nil {
+		return res, nil
+	}
+
+	merged, err := resource.Merge(res, base)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge base resource: %w", err)
+	}
+
+	return merged, nil
+}
+
+// envResource returns the resource attributes a deployer sets with
+// OTEL_RESOURCE_ATTRIBUTES, e.g. "team=checkout,region=eu", for newResource
+// to merge over the defaults.
+func envResource(ctx context.Context) (*resource.Resource, error) {
+	res, err := resource.New(ctx, resource.WithFromEnv())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OTEL_RESOURCE_ATTRIBUTES: %w", err)
+	}
+	return res, nil
+}
+
+// signalResource returns res with service.name replaced by the value of the
+// environment variable key when it is set, so that a single signal can be
+// reported, and routed, under a different service name.
+func signalResource(res *resource.Resource, key string) (*resource.Resource, error) {
+	name := os.Getenv(key)
+	if name == "" {
+		return res, nil
+	}
+
+	merged, err := resource.Merge(res, resource.NewSchemaless(attribute.String("service.name", name)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply %s: %w", key, err)
+	}
+
+	return merged, nil
+}
+
+// deploymentAttributes returns deployment.environment from DEPLOYMENT_ENV, or
+// nothing when it is unset.
+func deploymentAttributes() []attribute.KeyValue {
+	env := os.Getenv("DEPLOYMENT_ENV")
+	if env == "" {
+		return nil
+	}
+	return []attribute.KeyValue{attribute.String("deployment.environment", env)}
+}
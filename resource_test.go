@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestNewResourceMergesBase(t *testing.T) {
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "team=checkout,service.name=checkout-api")
+	ctx := context.Background()
+
+	base, err := envResource(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := newResource(ctx, defaultServiceName, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[attribute.Key]string{
+		// From the base resource
+		"team": "checkout",
+		// The base resource wins on conflict
+		"service.name": "checkout-api",
+		// Defaults
+		"library.language": "go",
+	}
+	set := res.Set()
+	for key, value := range want {
+		got, ok := set.Value(key)
+		if !ok {
+			t.Errorf("resource has no %s attribute", key)
+			continue
+		}
+		if got.AsString() != value {
+			t.Errorf("%s = %q, want %q", key, got.AsString(), value)
+		}
+	}
+}
+
+func TestSignalResource(t *testing.T) {
+	res, err := newResource(context.Background(), defaultServiceName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("OTEL_METRICS_SERVICE_NAME", "test-metrics")
+	t.Setenv("OTEL_TRACES_SERVICE_NAME", "test-traces")
+	t.Setenv("OTEL_LOGS_SERVICE_NAME", "")
+
+	want := map[string]string{
+		"OTEL_METRICS_SERVICE_NAME": "test-metrics",
+		"OTEL_TRACES_SERVICE_NAME":  "test-traces",
+		// Unset, so the shared name is kept
+		"OTEL_LOGS_SERVICE_NAME": defaultServiceName,
+	}
+	for key, name := range want {
+		signalRes, err := signalResource(res, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		set := signalRes.Set()
+		if got, _ := set.Value("service.name"); got.AsString() != name {
+			t.Errorf("with %s, service.name = %q, want %q", key, got.AsString(), name)
+		}
+		if !set.HasValue("library.language") {
+			t.Errorf("with %s, the other resource attributes were lost", key)
+		}
+	}
+}
+
+func TestResourceCPUAttributes(t *testing.T) {
+	res, err := newResource(context.Background(), defaultServiceName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set := res.Set()
+	want := map[attribute.Key]int64{
+		"host.cpu.count":             int64(runtime.NumCPU()),
+		"process.runtime.gomaxprocs": int64(runtime.GOMAXPROCS(0)),
+	}
+	for key, value := range want {
+		if got, ok := set.Value(key); !ok || got.AsInt64() != value {
+			t.Errorf("%s = %v, want %d", key, got.Emit(), value)
+		}
+	}
+}
+
+func TestResourceDetectors(t *testing.T) {
+	res, err := newResource(context.Background(), defaultServiceName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set := res.Set()
+	for _, key := range []attribute.Key{"host.name", "process.pid", "os.type", "service.name"} {
+		if !set.HasValue(key) {
+			t.Errorf("resource has no %s", key)
+		}
+	}
+}
+
+func TestResourceReleaseAttributes(t *testing.T) {
+	defer func(orig string) { serviceVersion = orig }(serviceVersion)
+	serviceVersion = "abc1234"
+
+	tests := []struct {
+		name, version, env string
+		want               map[attribute.Key]string
+	}{
+		{
+			name: "defaults",
+			want: map[attribute.Key]string{"service.version": "abc1234"},
+		},
+		{
+			name:    "from the environment",
+			version: "1.2.3",
+			env:     "staging",
+			want:    map[attribute.Key]string{"service.version": "1.2.3", "deployment.environment": "staging"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SERVICE_VERSION", tt.version)
+			t.Setenv("DEPLOYMENT_ENV", tt.env)
+
+			res, err := newResource(context.Background(), defaultServiceName, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			set := res.Set()
+			for key, value := range tt.want {
+				if got, _ := set.Value(key); got.AsString() != value {
+					t.Errorf("%s = %q, want %q", key, got.AsString(), value)
+				}
+			}
+			if tt.env == "" && set.HasValue("deployment.environment") {
+				t.Error("deployment.environment is set although DEPLOYMENT_ENV is not")
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type routeKey struct{}
+
+// withRoute stores pattern in the request context for next.
+func withRoute(pattern string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		next(w, r.WithContext(contextWithRoute(r.Context(), pattern)))
+	}
+}
+
+// contextWithRoute stores the pattern a request was routed by in ctx, so
+// telemetry can be broken down per endpoint without the cardinality of raw
+// paths.
+func contextWithRoute(ctx context.Context, pattern string) context.Context {
+	return context.WithValue(ctx, routeKey{}, pattern)
+}
+
+// routeFromContext returns the route pattern stored by contextWithRoute, or ""
+// if there is none.
+func routeFromContext(ctx context.Context) string {
+	route, _ := ctx.Value(routeKey{}).(string)
+	return route
+}
+
+// routeAttribute returns the http.route attribute for the request.
+func routeAttribute(r *http.Request) attribute.KeyValue {
+	return attribute.String("http.route", routeFromContext(r.Context()))
+}
+
+// httpAttributes returns the http.method and http.route span attributes for
+// the request.
+func httpAttributes(r *http.Request) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("http.method", r.Method),
+		routeAttribute(r),
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestRouteAttributes(t *testing.T) {
+	app, spans, reader := newTestApp(t, config{})
+	server, _, err := NewServer(app, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	want := map[string]attribute.Value{
+		"http.method": attribute.StringValue(http.MethodGet),
+		"http.route":  attribute.StringValue("/"),
+	}
+	attrs := attribute.NewSet(endedSpan(t, spans, "/").Attributes()...)
+	for key, value := range want {
+		if got, _ := attrs.Value(attribute.Key(key)); got != value {
+			t.Errorf("span %s = %v, want %v", key, got.Emit(), value.Emit())
+		}
+	}
+	if got, _ := attrs.Value("http.status_code"); got.AsInt64() != int64(rec.Code) {
+		t.Errorf("span http.status_code = %v, want %d", got.Emit(), rec.Code)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "api.request.latency_seconds" {
+				continue
+			}
+			for _, dp := range m.Data.(metricdata.Histogram[float64]).DataPoints {
+				if got, _ := dp.Attributes.Value("http.route"); got.AsString() != "/" {
+					t.Errorf("latency http.route = %q, want %q", got.AsString(), "/")
+				}
+			}
+			return
+		}
+	}
+	t.Error("no latency was recorded")
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// priorityBaggageKey is the baggage member that marks a request as high
+// priority. Carrying it as baggage makes it visible to the sampler, which runs
+// before span attributes are known, and to downstream services.
+const priorityBaggageKey = "sampling.priority"
+
+var (
+	// priorityHeader and priorityValue select the requests that are always
+	// sampled, e.g. "X-Priority: high".
+	priorityHeader = envString("PRIORITY_HEADER", "X-Priority")
+	priorityValue  = envString("PRIORITY_VALUE", "high")
+)
+
+// prioritySampler always samples high-priority requests and defers to base for
+// everything else.
+type prioritySampler struct {
+	base sdktrace.Sampler
+}
+
+func (s prioritySampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if baggage.FromContext(p.ParentContext).Member(priorityBaggageKey).Value() == priorityValue {
+		return sdktrace.SamplingResult{
+			Decision:   sdktrace.RecordAndSample,
+			Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+		}
+	}
+	return s.base.ShouldSample(p)
+}
+
+func (s prioritySampler) Description() string {
+	return fmt.Sprintf("PrioritySampler{%s=%s,%s}", priorityHeader, priorityValue, s.base.Description())
+}
+
+// withPriority copies the priority header into the request's baggage so that
+// prioritySampler can see it.
+func withPriority(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if value := r.Header.Get(priorityHeader); value != "" {
+			member, err := baggage.NewMember(priorityBaggageKey, value)
+			if err == nil {
+				b, err := baggage.FromContext(r.Context()).SetMember(member)
+				if err == nil {
+					r = r.WithContext(baggage.ContextWithBaggage(r.Context(), b))
+				}
+			}
+		}
+		next(w, r)
+	}
+}
+
+// samplerFromEnv builds the base sampler from OTEL_TRACES_SAMPLER and
+// OTEL_TRACES_SAMPLER_ARG. It defaults to parentbased_always_on so that
+// upstream sampling decisions are respected.
+func samplerFromEnv() sdktrace.Sampler {
+	name := envString("OTEL_TRACES_SAMPLER", "parentbased_always_on")
+
+	ratio := func() float64 {
+		ratio := envFloat("OTEL_TRACES_SAMPLER_ARG", 1)
+		if ratio < 0 || ratio > 1 {
+			log.Printf("invalid OTEL_TRACES_SAMPLER_ARG %v, using 1", ratio)
+			return 1
+		}
+		return ratio
+	}
+
+	switch name {
+	case "always_on":
+		return sdktrace.AlwaysSample()
+	case "always_off":
+		return sdktrace.NeverSample()
+	case "traceidratio":
+		return sdktrace.TraceIDRatioBased(ratio())
+	case "parentbased_always_on":
+		return sdktrace.ParentBased(sdktrace.AlwaysSample())
+	case "parentbased_always_off":
+		return sdktrace.ParentBased(sdktrace.NeverSample())
+	case "parentbased_traceidratio":
+		return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio()))
+	default:
+		log.Printf("invalid OTEL_TRACES_SAMPLER %q, using parentbased_always_on", name)
+		return sdktrace.ParentBased(sdktrace.AlwaysSample())
+	}
+}
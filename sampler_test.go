@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestPrioritySampler(t *testing.T) {
+	app, _, _ := newTestApp(t, config{})
+
+	ctx := context.Background()
+	spans := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(prioritySampler{base: sdktrace.NeverSample()}),
+		sdktrace.WithSpanProcessor(spans),
+	)
+	defer func() { _ = tp.Shutdown(ctx) }()
+	app.tracer = tp.Tracer("test")
+
+	handler := app.withMiddleware(app.otelMiddleware(app.cartAddHandler, "cartAddHandler"))
+	const requests = 5
+	for range requests {
+		r := httptest.NewRequest(http.MethodPost, "/cart/add", nil)
+		r.Header.Set(priorityHeader, priorityValue)
+		handler(httptest.NewRecorder(), r)
+
+		// Without the header the base sampler drops everything
+		handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/cart/add", nil))
+	}
+
+	var sampled int
+	for _, span := range spans.Ended() {
+		if span.Name() == "cartAddHandler" {
+			sampled++
+		}
+	}
+	if sampled != requests {
+		t.Errorf("sampled %d requests, want the %d high-priority ones", sampled, requests)
+	}
+}
+
+func TestSamplerFromEnv(t *testing.T) {
+	tests := []struct {
+		sampler, arg string
+		want         sdktrace.Sampler
+	}{
+		{sampler: "", want: sdktrace.ParentBased(sdktrace.AlwaysSample())},
+		{sampler: "always_on", want: sdktrace.AlwaysSample()},
+		{sampler: "always_off", want: sdktrace.NeverSample()},
+		{sampler: "traceidratio", arg: "0.25", want: sdktrace.TraceIDRatioBased(0.25)},
+		{sampler: "parentbased_traceidratio", arg: "0.5", want: sdktrace.ParentBased(sdktrace.TraceIDRatioBased(0.5))},
+		{sampler: "traceidratio", arg: "2", want: sdktrace.TraceIDRatioBased(1)},
+		{sampler: "sometimes", want: sdktrace.ParentBased(sdktrace.AlwaysSample())},
+	}
+	for _, tt := range tests {
+		t.Setenv("OTEL_TRACES_SAMPLER", tt.sampler)
+		t.Setenv("OTEL_TRACES_SAMPLER_ARG", tt.arg)
+		if got := samplerFromEnv().Description(); got != tt.want.Description() {
+			t.Errorf("OTEL_TRACES_SAMPLER=%q OTEL_TRACES_SAMPLER_ARG=%q: sampler = %s, want %s", tt.sampler, tt.arg, got, tt.want.Description())
+		}
+	}
+}
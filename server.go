@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// APIMux is a ServeMux whose routes all go through the shared middleware
+// chain and are traced in a span named after their pattern, so routes added by
+// an embedding application are instrumented the same way as the built-in ones.
+type APIMux struct {
+	*http.ServeMux
+	app *App
+}
+
+// NewAPIMux returns an empty APIMux whose routes are traced with app's tracer
+// and instruments.
+func NewAPIMux(app *App) *APIMux {
+	return &APIMux{ServeMux: http.NewServeMux(), app: app}
+}
+
+// HandleFunc registers handler for pattern behind the shared middleware.
+func (m *APIMux) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	m.ServeMux.HandleFunc(pattern, withRoute(pattern, m.app.withMiddleware(m.app.otelMiddleware(handler, pattern))))
+}
+
+// Handle registers handler for pattern behind the shared middleware.
+func (m *APIMux) Handle(pattern string, handler http.Handler) {
+	m.HandleFunc(pattern, handler.ServeHTTP)
+}
+
+// NewServer returns a server listening on addr with app's routes registered,
+// along with its mux so that callers can add their own routes. Pass a nil mux
+// to start from an empty one. It fails if mux already has a route that
+// conflicts with a built-in one.
+func NewServer(app *App, addr string, mux *APIMux) (server *http.Server, _ *APIMux, err error) {
+	if mux == nil {
+		mux = NewAPIMux(app)
+	}
+
+	// ServeMux panics on conflicting patterns
+	defer func() {
+		if r := recover(); r != nil {
+			server, err = nil, fmt.Errorf("failed to register routes: %v", r)
+		}
+	}()
+
+	mux.HandleFunc("/", app.helloWorldHandler)
+	mux.HandleFunc("/cart/add", app.cartAddHandler)
+	mux.HandleFunc("/cart/remove", app.cartRemoveHandler)
+	mux.HandleFunc("/cart/clear", app.cartClearHandler)
+	mux.HandleFunc("/chain", app.chainHandler)
+	mux.HandleFunc("/enqueue", app.enqueueHandler)
+	mux.HandleFunc("/correlate", app.correlateHandler)
+	mux.HandleFunc("/grpc", app.grpcHandler)
+	mux.HandleFunc("/stock", app.stockHandler)
+	mux.HandleFunc("/background-task", app.backgroundTaskHandler)
+
+	// Probes and operational routes skip the API middleware
+	if app.cfg.adminAddr == "" {
+		app.registerAdminRoutes(mux.ServeMux)
+	}
+	// Debug routes that are disabled, or served by the admin server, must not
+	// fall through to "/"
+	mux.ServeMux.Handle("/debug/", http.NotFoundHandler())
+
+	return &http.Server{Addr: addr, Handler: mux}, mux, nil
+}
+
+// NewAdminServer returns a server listening on addr with only app's probe and
+// debug routes registered.
+func NewAdminServer(app *App, addr string) *http.Server {
+	mux := http.NewServeMux()
+	app.registerAdminRoutes(mux)
+	return &http.Server{Addr: addr, Handler: mux}
+}
+
+// registerAdminRoutes adds the probe and debug routes to mux.
+func (a *App) registerAdminRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", healthzHandler)
+	mux.HandleFunc("/ready", readyHandler)
+	if envBool("ENABLE_DRAIN", false) {
+		mux.HandleFunc("/drain", drainHandler)
+	}
+	if a.cfg.debug {
+		mux.HandleFunc("/debug/spans", debugSpansHandler)
+		mux.HandleFunc("/debug/flush", a.debugFlushHandler)
+	}
+	if prometheusEnabled {
+		mux.Handle("/metrics", promhttp.Handler())
+	}
+	if pprofEnabled {
+		registerPprof(mux)
+	}
+}
+
+// prometheusEnabled serves metrics for Prometheus to scrape at /metrics, in
+// addition to pushing them over OTLP.
+var prometheusEnabled = envBool("ENABLE_PROMETHEUS", false)
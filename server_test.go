@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/prometheus"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+func TestCustomRouteIsTraced(t *testing.T) {
+	app, spans, _ := newTestApp(t, config{})
+	server, mux, err := NewServer(app, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.HandleFunc("/custom", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/custom", nil))
+	if rec.Code != http.StatusTeapot {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusTeapot)
+	}
+
+	span := endedSpan(t, spans, "/custom")
+	var route string
+	for _, kv := range span.Attributes() {
+		if kv.Key == "http.route" {
+			route = kv.Value.AsString()
+		}
+	}
+	if route != "/custom" {
+		t.Errorf("http.route = %q, want %q", route, "/custom")
+	}
+}
+
+func TestBuiltinRoutesUseMiddleware(t *testing.T) {
+	app, spans, reader := newTestApp(t, config{})
+	server, _, err := NewServer(app, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := []struct {
+		method, target, route string
+	}{
+		{http.MethodPost, "/enqueue", "/enqueue"},
+		{http.MethodPost, "/correlate", "/correlate"},
+		{http.MethodGet, "/grpc", "/grpc"},
+		{http.MethodGet, "/stock?item=apple", "/stock"},
+		// Rejected, so no task outlives the test
+		{http.MethodGet, "/background-task", "/background-task"},
+	}
+	for _, req := range requests {
+		server.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(req.method, req.target, nil))
+	}
+
+	names := map[string]bool{}
+	for _, span := range spans.Ended() {
+		names[span.Name()] = true
+	}
+	for _, req := range requests {
+		if !names[req.route] {
+			t.Errorf("no span named %q among %v", req.route, names)
+		}
+	}
+
+	if calls := collectInt64Sum(t, reader, "api.request.counter"); calls != int64(len(requests)) {
+		t.Errorf("api.request.counter = %d, want %d", calls, len(requests))
+	}
+}
+
+func TestNewServerConflictingRoute(t *testing.T) {
+	app, _, _ := newTestApp(t, config{})
+	mux := NewAPIMux(app)
+	mux.HandleFunc("/stock", func(http.ResponseWriter, *http.Request) {})
+
+	if _, _, err := NewServer(app, "", mux); err == nil {
+		t.Error("NewServer accepted a mux with a conflicting /stock route")
+	}
+}
+
+func TestNewAppServesWithoutMain(t *testing.T) {
+	downstream := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer downstream.Close()
+	// Only what NewApp and NewServer set up, with the global providers left
+	// as they are
+	app, err := NewApp(config{chainURL: downstream.URL}, otel.Tracer("test"), otel.Meter("test"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, _, err := NewServer(app, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routes := []struct{ method, target string }{
+		{http.MethodGet, "/"},
+		{http.MethodPost, "/cart/add"},
+		{http.MethodPost, "/cart/remove"},
+		{http.MethodPost, "/cart/clear"},
+		{http.MethodGet, "/chain"},
+		{http.MethodPost, "/enqueue"},
+		{http.MethodPost, "/correlate"},
+		{http.MethodGet, "/grpc"},
+		{http.MethodGet, "/stock?item=widget"},
+		{http.MethodPost, "/background-task"},
+	}
+	for _, route := range routes {
+		rec := httptest.NewRecorder()
+		server.Handler.ServeHTTP(rec, httptest.NewRequest(route.method, route.target, nil))
+		if rec.Code >= http.StatusInternalServerError {
+			t.Errorf("%s %s: status = %d", route.method, route.target, rec.Code)
+		}
+	}
+}
+
+func TestPrometheusEndpoint(t *testing.T) {
+	defer func(orig bool) { prometheusEnabled = orig }(prometheusEnabled)
+	prometheusEnabled = true
+	app, _, _ := newTestApp(t, config{})
+	ctx := context.Background()
+
+	exporter, err := prometheus.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(exporter))
+	defer func() { _ = mp.Shutdown(ctx) }()
+	counter, err := mp.Meter("test").Int64Counter("api.request.counter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter.Add(ctx, 3)
+
+	rec := httptest.NewRecorder()
+	NewAdminServer(app, "").Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "api_request_counter_total") {
+		t.Errorf("/metrics doesn't report api.request.counter:\n%s", body)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// recordShutdown reports whether shutting down a provider succeeded, so that
+// chronically failing shutdowns can be spotted across a fleet. The outcome is
+// always logged: to the logs signal while the logger provider is running, and
+// to stderr once it has shut down. It is also counted, but the counter only
+// reaches the collector while the meter provider is still running, i.e. for
+// the tracer provider, which is shut down first.
+func (a *App) recordShutdown(ctx context.Context, provider string, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+		logger.Info("provider shutdown", "provider", provider, "outcome", outcome, "error", err)
+	} else {
+		logger.Info("provider shutdown", "provider", provider, "outcome", outcome)
+	}
+
+	if a.shutdownCounter != nil {
+		a.shutdownCounter.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("provider", provider),
+			attribute.String("outcome", outcome),
+		))
+	}
+}
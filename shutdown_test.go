@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestRecordShutdown(t *testing.T) {
+	app, _, reader := newTestApp(t, config{})
+	ctx := context.Background()
+
+	var logs bytes.Buffer
+	origLogger := logger
+	defer func() { logger = origLogger }()
+	logger = slog.New(slog.NewTextHandler(&logs, nil))
+
+	app.recordShutdown(ctx, "tracer", nil)
+	app.recordShutdown(ctx, "logger", errors.New("simulated shutdown failure"))
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	outcomes := map[string]string{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "otel.sdk.shutdown" {
+				continue
+			}
+			for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				provider, _ := dp.Attributes.Value("provider")
+				outcome, _ := dp.Attributes.Value("outcome")
+				outcomes[provider.AsString()] = outcome.AsString()
+			}
+		}
+	}
+	for provider, want := range map[string]string{"tracer": "success", "logger": "failure"} {
+		if got := outcomes[provider]; got != want {
+			t.Errorf("%s shutdown outcome = %q, want %q", provider, got, want)
+		}
+		if line := "provider=" + provider + " outcome=" + want; !strings.Contains(logs.String(), line) {
+			t.Errorf("logs %q don't contain %q", logs.String(), line)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// startupDelay is how long the app waits before setting up telemetry and
+// serving.
+var startupDelay = envDuration("STARTUP_DELAY", 0)
+
+// delayStartup sleeps for delay, if positive.
+func delayStartup(delay time.Duration) {
+	if delay <= 0 {
+		return
+	}
+	log.Printf("Delaying startup by %s", delay)
+	time.Sleep(delay)
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDelayStartup(t *testing.T) {
+	const delay = 50 * time.Millisecond
+
+	start := time.Now()
+	delayStartup(delay)
+	if elapsed := time.Since(start); elapsed < delay {
+		t.Errorf("delayStartup(%s) returned after %s", delay, elapsed)
+	}
+
+	start = time.Now()
+	delayStartup(0)
+	if elapsed := time.Since(start); elapsed >= delay {
+		t.Errorf("delayStartup(0) returned after %s", elapsed)
+	}
+}
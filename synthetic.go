@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// syntheticHeader is the request header that marks load-test or otherwise
+// simulated traffic, e.g. "X-Synthetic: true".
+var syntheticHeader = envString("SYNTHETIC_HEADER", "X-Synthetic")
+
+// isSynthetic reports whether the request is simulated traffic, either by the
+// synthetic header or by hitting a /simulate path.
+func isSynthetic(r *http.Request) bool {
+	if strings.HasPrefix(r.URL.Path, "/simulate") {
+		return true
+	}
+	synthetic, _ := strconv.ParseBool(r.Header.Get(syntheticHeader))
+	return synthetic
+}
+
+// syntheticAttributes returns the attributes that tag simulated traffic so it
+// can be excluded from SLOs. Normal traffic gets no extra attributes.
+func syntheticAttributes(r *http.Request) []attribute.KeyValue {
+	if !isSynthetic(r) {
+		return nil
+	}
+	return []attribute.KeyValue{attribute.Bool("synthetic", true)}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestSyntheticTraffic(t *testing.T) {
+	tests := []struct {
+		name      string
+		synthetic bool
+	}{
+		{name: "synthetic", synthetic: true},
+		{name: "normal", synthetic: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, spans, reader := newTestApp(t, config{})
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.synthetic {
+				r.Header.Set(syntheticHeader, "true")
+			}
+			app.otelMiddleware(app.helloWorldHandler, "helloWorldHandler")(httptest.NewRecorder(), r)
+
+			attrs := attribute.NewSet(endedSpan(t, spans, "helloWorldHandler").Attributes()...)
+			if got := attrs.HasValue("synthetic"); got != tt.synthetic {
+				t.Errorf("span tagged synthetic = %t, want %t", got, tt.synthetic)
+			}
+
+			var rm metricdata.ResourceMetrics
+			if err := reader.Collect(context.Background(), &rm); err != nil {
+				t.Fatal(err)
+			}
+			for _, sm := range rm.ScopeMetrics {
+				for _, m := range sm.Metrics {
+					if m.Name != "api.request.latency_seconds" {
+						continue
+					}
+					for _, dp := range m.Data.(metricdata.Histogram[float64]).DataPoints {
+						if got := dp.Attributes.HasValue("synthetic"); got != tt.synthetic {
+							t.Errorf("request latency tagged synthetic = %t, want %t", got, tt.synthetic)
+						}
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// grpcTransportCredentials returns the credentials for dialing endpoint over
+// gRPC, as decided by otlpTLSConfig.
+func grpcTransportCredentials(endpoint string) (credentials.TransportCredentials, error) {
+	tlsConfig, err := otlpTLSConfig(endpoint)
+	if err != nil || tlsConfig == nil {
+		return insecure.NewCredentials(), err
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// otlpTLSConfig returns the TLS configuration for exporting to endpoint, or
+// nil to export without TLS. TLS is used unless OTEL_EXPORTER_OTLP_INSECURE is
+// true, which is the default only for local collectors. The server certificate
+// is verified against the system pool plus the CA in
+// OTEL_EXPORTER_OTLP_CERTIFICATE, if set.
+func otlpTLSConfig(endpoint string) (*tls.Config, error) {
+	if otlpInsecure(endpoint) {
+		return nil, nil
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+
+	if path := os.Getenv("OTEL_EXPORTER_OTLP_CERTIFICATE"); path != "" {
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read collector CA certificate: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", path)
+		}
+	}
+
+	return &tls.Config{RootCAs: pool}, nil
+}
+
+// insecureFlag is set by the -insecure flag, overriding otlpInsecure for
+// every endpoint.
+var insecureFlag *bool
+
+// otlpInsecure reports whether to connect to endpoint without TLS.
+func otlpInsecure(endpoint string) bool {
+	if insecureFlag != nil {
+		return *insecureFlag
+	}
+
+	host, _, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		host = endpoint
+	}
+	local := host == "localhost"
+	if ip := net.ParseIP(host); ip != nil {
+		local = ip.IsLoopback()
+	}
+
+	return envBool("OTEL_EXPORTER_OTLP_INSECURE", local)
+}
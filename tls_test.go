@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestOTLPInsecureDefault(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		want     bool
+	}{
+		{endpoint: "localhost:4317", want: true},
+		{endpoint: "127.0.0.1:4317", want: true},
+		{endpoint: "[::1]:4317", want: true},
+		{endpoint: "collector.example.com:4317", want: false},
+		{endpoint: "10.0.0.5:4317", want: false},
+	}
+	for _, tt := range tests {
+		if got := otlpInsecure(tt.endpoint); got != tt.want {
+			t.Errorf("otlpInsecure(%q) = %t, want %t", tt.endpoint, got, tt.want)
+		}
+	}
+
+	t.Setenv("OTEL_EXPORTER_OTLP_INSECURE", "true")
+	if tlsConfig, err := otlpTLSConfig("collector.example.com:4317"); err != nil || tlsConfig != nil {
+		t.Errorf("with OTEL_EXPORTER_OTLP_INSECURE=true, otlpTLSConfig = %v, %v, want no TLS", tlsConfig, err)
+	}
+}
+
+func TestOTLPInsecureFlag(t *testing.T) {
+	defer func(orig *bool) { insecureFlag = orig }(insecureFlag)
+	t.Setenv("OTEL_EXPORTER_OTLP_INSECURE", "true")
+
+	secure := false
+	insecureFlag = &secure
+	if otlpInsecure("localhost:4317") {
+		t.Error("-insecure=false didn't override OTEL_EXPORTER_OTLP_INSECURE")
+	}
+}
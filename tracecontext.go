@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// validateTraceContext controls whether malformed traceparent headers are
+// reported. The propagator otherwise drops them silently, hiding upstream
+// misconfiguration.
+var validateTraceContext = envBool("VALIDATE_TRACE_CONTEXT", true)
+
+// invalidTraceContextKey marks spans started for a request whose traceparent
+// header was malformed.
+const invalidTraceContextKey = attribute.Key("trace.context.invalid")
+
+type invalidTraceContextContextKey struct{}
+
+// withTraceContextValidation counts requests with a malformed traceparent
+// header and flags them in the request context, so that the root span they
+// start instead can be tagged.
+func (a *App) withTraceContextValidation(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if validateTraceContext {
+			if header := r.Header.Get("traceparent"); header != "" && !parseTraceparent(header).IsValid() {
+				a.invalidTraceContextCounter.Add(r.Context(), 1)
+				r = r.WithContext(context.WithValue(r.Context(), invalidTraceContextContextKey{}, true))
+			}
+		}
+		next(w, r)
+	}
+}
+
+// extractTraceContext continues the caller's trace by extracting the
+// traceparent and baggage headers into the request context, so that server
+// spans attach to the upstream trace.
+func extractTraceContext(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// invalidTraceContextProcessor tags the root span of a request that carried a
+// malformed traceparent header.
+type invalidTraceContextProcessor struct{ noopProcessor }
+
+func (invalidTraceContextProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	if invalid, _ := parent.Value(invalidTraceContextContextKey{}).(bool); invalid && !trace.SpanContextFromContext(parent).IsValid() {
+		s.SetAttributes(invalidTraceContextKey.Bool(true))
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestInvalidTraceparent(t *testing.T) {
+	usePropagator(t)
+	app, _, reader := newTestApp(t, config{})
+	spans := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(invalidTraceContextProcessor{}),
+		sdktrace.WithSpanProcessor(spans),
+	)
+	defer func() { _ = tp.Shutdown(context.Background()) }()
+	app.tracer = tp.Tracer("test")
+
+	server, _, err := NewServer(app, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("traceparent", "00-not-a-valid-traceparent-01")
+	server.Handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	if n := collectInt64Sum(t, reader, "trace.context.invalid"); n != 1 {
+		t.Errorf("trace.context.invalid = %d, want 1", n)
+	}
+	span := endedSpan(t, spans, "/")
+	if span.Parent().IsValid() {
+		t.Errorf("span has parent %v, want a new root span", span.Parent())
+	}
+	var flagged bool
+	for _, kv := range span.Attributes() {
+		if kv.Key == invalidTraceContextKey && kv.Value.AsBool() {
+			flagged = true
+		}
+	}
+	if !flagged {
+		t.Errorf("root span is not tagged %s", invalidTraceContextKey)
+	}
+}
+
+func TestTraceparentExtracted(t *testing.T) {
+	app, spans, _ := newTestApp(t, config{})
+	usePropagator(t)
+	server, _, err := NewServer(app, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("traceparent", traceparent)
+	server.Handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	span := endedSpan(t, spans, "/")
+	if want := parseTraceparent(traceparent); !span.Parent().Equal(want) {
+		t.Errorf("span parent = %v, want %v", span.Parent(), want)
+	}
+	if got, want := span.SpanContext().TraceID().String(), "4bf92f3577b34da6a3ce929d0e0e4736"; got != want {
+		t.Errorf("trace ID = %s, want %s", got, want)
+	}
+}
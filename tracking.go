@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// lastExportSuccess holds the time, in Unix nanoseconds, of the last export
+// that succeeded for any signal. It starts at process start so that a pipeline
+// that never works still shows a growing age.
+var lastExportSuccess atomic.Int64
+
+func init() {
+	markExportSuccess()
+}
+
+// lastExportedResource holds, per signal ("traces" or "metrics"), the resource
+// attached to the most recently exported telemetry. Tests use exportedResource
+// to check that resource detection and merging produced exactly the intended
+// attributes. Signals can be given different resources, so they are kept
+// apart.
+var lastExportedResource = map[string]*atomic.Pointer[resource.Resource]{
+	"traces":  {},
+	"metrics": {},
+}
+
+// exportedResource returns the resource attached to the most recently exported
+// telemetry of signal, or nil if nothing was exported yet.
+func exportedResource(signal string) *resource.Resource {
+	return lastExportedResource[signal].Load()
+}
+
+// markExportSuccess records that an export just succeeded.
+func markExportSuccess() {
+	lastExportSuccess.Store(time.Now().UnixNano())
+}
+
+// lastExportAge returns the time elapsed since the last successful export.
+func lastExportAge() time.Duration {
+	return time.Since(time.Unix(0, lastExportSuccess.Load()))
+}
+
+// spanDeliveryTracker counts spans handed to the batch processor and spans the
+// exporter accepted, so that spans lost in between can be reported.
+type spanDeliveryTracker struct {
+	ended    atomic.Int64
+	exported atomic.Int64
+}
+
+// dropped returns the number of ended spans that never reached the exporter.
+func (t *spanDeliveryTracker) dropped() int64 {
+	return t.ended.Load() - t.exported.Load()
+}
+
+// spanTrackers holds the tracker of every batch processor, one per exporter.
+var (
+	spanTrackersMu sync.Mutex
+	spanTrackers   []*spanDeliveryTracker
+)
+
+// newSpanDeliveryTracker returns a tracker that contributes to
+// spanDeliveryRatio.
+func newSpanDeliveryTracker() *spanDeliveryTracker {
+	t := &spanDeliveryTracker{}
+	spanTrackersMu.Lock()
+	spanTrackers = append(spanTrackers, t)
+	spanTrackersMu.Unlock()
+	return t
+}
+
+// spanDeliveryRatio returns the fraction of sampled spans that exporters
+// accepted, across all exporters. ok is false until a span has ended. Spans
+// still queued in a batch processor count as not delivered yet.
+func spanDeliveryRatio() (ratio float64, ok bool) {
+	spanTrackersMu.Lock()
+	defer spanTrackersMu.Unlock()
+
+	var ended, exported int64
+	for _, t := range spanTrackers {
+		ended += t.ended.Load()
+		exported += t.exported.Load()
+	}
+	if ended == 0 {
+		return 0, false
+	}
+	return float64(exported) / float64(ended), true
+}
+
+// trackingSpanProcessor wraps a span processor and reports spans that were
+// still queued (or dropped on a full queue) when it was shut down.
+type trackingSpanProcessor struct {
+	sdktrace.SpanProcessor
+	tracker *spanDeliveryTracker
+	dropped metric.Int64Counter
+}
+
+func (p *trackingSpanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	p.tracker.ended.Add(1)
+	p.SpanProcessor.OnEnd(s)
+}
+
+func (p *trackingSpanProcessor) Shutdown(ctx context.Context) error {
+	err := p.SpanProcessor.Shutdown(ctx)
+
+	if dropped := p.tracker.dropped(); dropped > 0 {
+		log.Printf("%d spans were dropped before they could be exported", dropped)
+		if p.dropped != nil {
+			p.dropped.Add(ctx, dropped)
+		}
+	}
+
+	return err
+}
+
+// trackingSpanExporter wraps a span exporter and counts successfully exported
+// spans.
+type trackingSpanExporter struct {
+	sdktrace.SpanExporter
+	tracker *spanDeliveryTracker
+}
+
+func (e *trackingSpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) > 0 {
+		lastExportedResource["traces"].Store(spans[0].Resource())
+	}
+
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	exportBreaker.record(e, err)
+	if err == nil {
+		e.tracker.exported.Add(int64(len(spans)))
+		markExportSuccess()
+	}
+	return err
+}
+
+// trackingMetricExporter wraps a metric exporter and records when exports
+// succeed.
+type trackingMetricExporter struct {
+	sdkmetric.Exporter
+}
+
+func (e *trackingMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	lastExportedResource["metrics"].Store(rm.Resource)
+
+	err := e.Exporter.Export(ctx, rm)
+	if err == nil {
+		markExportSuccess()
+	}
+	return err
+}
+
+// attributeCountProcessor records how many attributes each ended span carries
+// in histogram, so spans with runaway attributes stand out.
+type attributeCountProcessor struct {
+	noopProcessor
+	histogram metric.Int64Histogram
+}
+
+func (p attributeCountProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if p.histogram != nil {
+		p.histogram.Record(context.Background(), int64(len(s.Attributes())))
+	}
+}
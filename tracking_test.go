@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// blockingSpanExporter holds every export until release is closed.
+type blockingSpanExporter struct {
+	release  chan struct{}
+	exported atomic.Int64
+}
+
+func (e *blockingSpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	<-e.release
+	e.exported.Add(int64(len(spans)))
+	return nil
+}
+
+func (e *blockingSpanExporter) Shutdown(context.Context) error { return nil }
+
+func TestDroppedSpansAtShutdown(t *testing.T) {
+	app, _, reader := newTestApp(t, config{})
+	ctx := context.Background()
+
+	// The exporter is stuck, so all but the spans it and the one-span queue
+	// hold are dropped
+	exporter := &blockingSpanExporter{release: make(chan struct{})}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(newBatchSpanProcessor(exporter, app.droppedSpansCounter,
+		sdktrace.WithMaxQueueSize(1),
+		sdktrace.WithMaxExportBatchSize(1),
+	)))
+	const spans = 10
+	for range spans {
+		_, span := tp.Tracer("test").Start(ctx, "span")
+		span.End()
+	}
+	close(exporter.release)
+	if err := tp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	dropped := collectInt64Sum(t, reader, "otel.sdk.span.dropped")
+	if dropped == 0 {
+		t.Fatal("no dropped spans were reported")
+	}
+	if want := spans - exporter.exported.Load(); dropped != want {
+		t.Errorf("dropped spans = %d, want %d", dropped, want)
+	}
+}
+
+func TestLastExportAge(t *testing.T) {
+	exporter := &trackingSpanExporter{SpanExporter: tracetest.NewInMemoryExporter(), tracker: &spanDeliveryTracker{}}
+	if err := exporter.ExportSpans(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if age := lastExportAge(); age > time.Second {
+		t.Fatalf("age right after an export = %s, want near zero", age)
+	}
+
+	before := lastExportAge()
+	time.Sleep(20 * time.Millisecond)
+	if after := lastExportAge(); after < before+20*time.Millisecond {
+		t.Errorf("age grew from %s to %s, want at least 20ms more", before, after)
+	}
+}
+
+func TestAttributeCountProcessor(t *testing.T) {
+	app, _, reader := newTestApp(t, config{})
+	ctx := context.Background()
+
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(attributeCountProcessor{histogram: app.spanAttributeCountHistogram}))
+	defer func() { _ = tp.Shutdown(ctx) }()
+	_, span := tp.Tracer("test").Start(ctx, "span", trace.WithAttributes(
+		attribute.Int("a", 1),
+		attribute.Int("b", 2),
+		attribute.Int("c", 3),
+	))
+	span.End()
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "otel.span.attribute_count" {
+				continue
+			}
+			dp := m.Data.(metricdata.Histogram[int64]).DataPoints[0]
+			if dp.Count != 1 || dp.Sum != 3 {
+				t.Errorf("recorded %d spans with %d attributes, want 1 with 3", dp.Count, dp.Sum)
+			}
+			return
+		}
+	}
+	t.Error("no otel.span.attribute_count metric was collected")
+}
+
+func TestSpansReachEveryExporter(t *testing.T) {
+	app, _, _ := newTestApp(t, config{})
+	primary := tracetest.NewInMemoryExporter()
+	secondary := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(tracerProviderOptions(app, resource.Empty(),
+		[]sdktrace.SpanExporter{primary, secondary})...)
+	defer tp.Shutdown(context.Background())
+
+	_, span := tp.Tracer("test").Start(context.Background(), "dual-write")
+	span.End()
+	if err := tp.ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := len(primary.GetSpans()); got != 1 {
+		t.Errorf("primary exporter got %d spans, want 1", got)
+	}
+	if got := len(secondary.GetSpans()); got != 1 {
+		t.Errorf("secondary exporter got %d spans, want 1", got)
+	}
+}
+
+func TestExportedResource(t *testing.T) {
+	ctx := context.Background()
+	res, err := newResource(ctx, defaultServiceName, resource.NewSchemaless(attribute.String("team", "checkout")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Traces reported under a service name of their own
+	tracesRes, err := resource.Merge(res, resource.NewSchemaless(attribute.String("service.name", "test-traces")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithResource(tracesRes),
+		sdktrace.WithSpanProcessor(newBatchSpanProcessor(tracetest.NewInMemoryExporter(), nil)),
+	)
+	defer func() { _ = tp.Shutdown(ctx) }()
+	_, span := tp.Tracer("test").Start(ctx, "span")
+	span.End()
+	if err := tp.ForceFlush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The resource is captured whether or not the export succeeds
+	metricExporter := &trackingMetricExporter{Exporter: failingMetricExporter{}}
+	_ = metricExporter.Export(ctx, &metricdata.ResourceMetrics{Resource: res})
+
+	for signal, want := range map[string]*resource.Resource{"traces": tracesRes, "metrics": res} {
+		got := exportedResource(signal)
+		if got == nil {
+			t.Errorf("no %s were exported", signal)
+			continue
+		}
+		if !got.Equal(want) {
+			t.Errorf("exported %s resource = %v, want %v", signal, got, want)
+		}
+	}
+}
+
+func TestSpanDeliveryRatio(t *testing.T) {
+	spanTrackersMu.Lock()
+	orig := spanTrackers
+	spanTrackers = nil
+	spanTrackersMu.Unlock()
+	defer func() {
+		spanTrackersMu.Lock()
+		spanTrackers = orig
+		spanTrackersMu.Unlock()
+	}()
+
+	first, second := newSpanDeliveryTracker(), newSpanDeliveryTracker()
+	if _, ok := spanDeliveryRatio(); ok {
+		t.Error("a ratio was reported before any span ended")
+	}
+
+	first.ended.Store(10)
+	first.exported.Store(10)
+	second.ended.Store(10)
+	second.exported.Store(5)
+	if ratio, ok := spanDeliveryRatio(); !ok || ratio != 0.75 {
+		t.Errorf("spanDeliveryRatio = %v, %t, want 0.75, true", ratio, ok)
+	}
+}
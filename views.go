@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// latencyBucketBoundaries are the bucket boundaries, in seconds, of
+// api.request.latency_seconds, sized for sub-second web latencies.
+var latencyBucketBoundaries = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// latencyHistogramView sets the aggregation of the request latency histogram:
+// latencyBucketBoundaries, or with OTEL_HISTOGRAM_AGGREGATION=exponential a
+// base-2 exponential histogram that keeps full resolution for backends that
+// support it.
+func latencyHistogramView() sdkmetric.View {
+	var aggregation sdkmetric.Aggregation = sdkmetric.AggregationExplicitBucketHistogram{
+		Boundaries: latencyBucketBoundaries,
+	}
+
+	switch kind := envString("OTEL_HISTOGRAM_AGGREGATION", "explicit"); kind {
+	case "explicit":
+	case "exponential":
+		aggregation = sdkmetric.AggregationBase2ExponentialHistogram{
+			MaxSize:  int32(envInt("OTEL_HISTOGRAM_MAX_SIZE", 160)),
+			MaxScale: int32(envInt("OTEL_HISTOGRAM_MAX_SCALE", 20)),
+		}
+	default:
+		log.Printf("invalid OTEL_HISTOGRAM_AGGREGATION %q, using explicit", kind)
+	}
+
+	return sdkmetric.NewView(
+		sdkmetric.Instrument{Name: "api.request.latency_seconds"},
+		sdkmetric.Stream{Aggregation: aggregation},
+	)
+}
+
+// requestMetricAttributeKeys are the attributes kept on the api.request.*
+// metrics: the HTTP route, method and status code, plus the flags the app
+// itself breaks requests down by.
+var requestMetricAttributeKeys = []attribute.Key{
+	"http.route",
+	"http.method",
+	"http.status_code",
+	"error",
+	"synthetic",
+	canaryAttribute.Key,
+}
+
+// requestMetricsAttributeView drops every attribute not in
+// requestMetricAttributeKeys from the request metrics, so that a
+// high-cardinality attribute added by mistake, e.g. a raw URL with IDs in it,
+// can't blow up the number of series the SDK keeps in memory.
+func requestMetricsAttributeView() sdkmetric.View {
+	return sdkmetric.NewView(
+		sdkmetric.Instrument{Name: "api.request.*"},
+		sdkmetric.Stream{AttributeFilter: attribute.NewAllowKeysFilter(requestMetricAttributeKeys...)},
+	)
+}
+
+// instrumentOverride is the description and unit to apply to an instrument,
+// as given in INSTRUMENT_OVERRIDES.
+type instrumentOverride struct {
+	Description string `json:"description"`
+	Unit        string `json:"unit"`
+}
+
+// instrumentOverrideViews parses a JSON object mapping instrument names to
+// {description, unit} overrides, e.g.
+//
+//	{"api.request.latency_seconds": {"description": "Request latency", "unit": "s"}}
+//
+// and returns a view for each entry.
+func instrumentOverrideViews(raw string) ([]sdkmetric.View, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var overrides map[string]instrumentOverride
+	if err := json.Unmarshal([]byte(raw), &overrides); err != nil {
+		return nil, fmt.Errorf("failed to parse instrument overrides: %w", err)
+	}
+
+	// Sort so that errors and views are reported in a stable order.
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	views := make([]sdkmetric.View, 0, len(names))
+	for _, name := range names {
+		o := overrides[name]
+		if o.Unit != "" {
+			if err := validateUnit(o.Unit); err != nil {
+				return nil, fmt.Errorf("instrument override for %q: %w", name, err)
+			}
+		}
+		views = append(views, sdkmetric.NewView(
+			sdkmetric.Instrument{Name: name},
+			sdkmetric.Stream{Description: o.Description, Unit: o.Unit},
+		))
+	}
+
+	return views, nil
+}
+
+// validateUnit loosely checks a unit against UCUM case-sensitive syntax: at
+// most 63 printable ASCII characters without spaces, with balanced {}
+// annotations.
+func validateUnit(unit string) error {
+	if len(unit) > 63 {
+		return fmt.Errorf("unit %q is longer than 63 characters", unit)
+	}
+
+	depth := 0
+	for _, c := range unit {
+		switch {
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth < 0 {
+				return fmt.Errorf("unit %q has an unmatched '}'", unit)
+			}
+		case c <= ' ' || c > '~':
+			return fmt.Errorf("unit %q contains invalid character %q", unit, c)
+		}
+	}
+	if depth != 0 {
+		return fmt.Errorf("unit %q has an unmatched '{'", unit)
+	}
+
+	return nil
+}
+
+// combineViews merges views into one, so that several views matching the same
+// instrument refine a single stream instead of each producing a duplicate
+// stream. Later views win when they set the same field.
+func combineViews(views ...sdkmetric.View) sdkmetric.View {
+	return func(i sdkmetric.Instrument) (sdkmetric.Stream, bool) {
+		out := sdkmetric.Stream{Name: i.Name, Description: i.Description, Unit: i.Unit}
+		matched := false
+
+		for _, view := range views {
+			s, ok := view(i)
+			if !ok {
+				continue
+			}
+			matched = true
+
+			if s.Name != i.Name {
+				out.Name = s.Name
+			}
+			if s.Description != i.Description {
+				out.Description = s.Description
+			}
+			if s.Unit != i.Unit {
+				out.Unit = s.Unit
+			}
+			if s.Aggregation != nil {
+				out.Aggregation = s.Aggregation
+			}
+			if s.AttributeFilter != nil {
+				out.AttributeFilter = andFilters(out.AttributeFilter, s.AttributeFilter)
+			}
+		}
+
+		return out, matched
+	}
+}
+
+// andFilters returns a filter keeping only attributes that both a and b keep.
+func andFilters(a, b attribute.Filter) attribute.Filter {
+	if a == nil {
+		return b
+	}
+	return func(kv attribute.KeyValue) bool {
+		return a(kv) && b(kv)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestInstrumentOverrideViews(t *testing.T) {
+	overrides, err := instrumentOverrideViews(`{"api.request.error_counter": {"description": "Failed requests", "unit": "{req}"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// Overrides are combined into a single view, as in initMeterProvider
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(reader),
+		sdkmetric.WithView(combineViews(overrides...)),
+	)
+	defer func() { _ = mp.Shutdown(ctx) }()
+	counter, err := mp.Meter("test").Int64Counter("api.request.error_counter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter.Add(ctx, 1)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(rm.ScopeMetrics[0].Metrics); n != 1 {
+		t.Fatalf("got %d streams, want 1", n)
+	}
+	m := rm.ScopeMetrics[0].Metrics[0]
+	if m.Description != "Failed requests" {
+		t.Errorf("description = %q, want %q", m.Description, "Failed requests")
+	}
+	if m.Unit != "{req}" {
+		t.Errorf("unit = %q, want %q", m.Unit, "{req}")
+	}
+}
+
+// collectLatency records value on a latency histogram aggregated by
+// latencyHistogramView and returns the collected data.
+func collectLatency(t *testing.T, value float64) metricdata.Aggregation {
+	t.Helper()
+	ctx := context.Background()
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(reader),
+		sdkmetric.WithView(combineViews(latencyHistogramView())),
+	)
+	defer func() { _ = mp.Shutdown(ctx) }()
+	histogram, err := mp.Meter("test").Float64Histogram("api.request.latency_seconds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	histogram.Record(ctx, value)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	return rm.ScopeMetrics[0].Metrics[0].Data
+}
+
+func TestLatencyHistogramView(t *testing.T) {
+	got := collectLatency(t, 0.03)
+	data, ok := got.(metricdata.Histogram[float64])
+	if !ok {
+		t.Fatalf("got %T, want a float64 histogram", got)
+	}
+	dp := data.DataPoints[0]
+	if !slices.Equal(dp.Bounds, latencyBucketBoundaries) {
+		t.Errorf("bounds = %v, want %v", dp.Bounds, latencyBucketBoundaries)
+	}
+	// 0.03 falls in (0.025, 0.05]
+	if dp.BucketCounts[3] != 1 {
+		t.Errorf("bucket counts = %v, want the sample in bucket 3", dp.BucketCounts)
+	}
+}
+
+func TestLatencyHistogramViewExponential(t *testing.T) {
+	t.Setenv("OTEL_HISTOGRAM_AGGREGATION", "exponential")
+	t.Setenv("OTEL_HISTOGRAM_MAX_SCALE", "5")
+
+	got := collectLatency(t, 0.03)
+	data, ok := got.(metricdata.ExponentialHistogram[float64])
+	if !ok {
+		t.Fatalf("got %T, want a float64 exponential histogram", got)
+	}
+	dp := data.DataPoints[0]
+	if dp.Count != 1 {
+		t.Errorf("count = %d, want 1", dp.Count)
+	}
+	if dp.Scale > 5 {
+		t.Errorf("scale = %d, want at most 5", dp.Scale)
+	}
+}
+
+func TestRequestMetricsAttributeView(t *testing.T) {
+	ctx := context.Background()
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(reader),
+		sdkmetric.WithView(combineViews(latencyHistogramView(), requestMetricsAttributeView())),
+	)
+	defer func() { _ = mp.Shutdown(ctx) }()
+	histogram, err := mp.Meter("test").Float64Histogram("api.request.latency_seconds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A raw URL is a new series per ID
+	for _, url := range []string{"/cart/1", "/cart/2"} {
+		histogram.Record(ctx, 0.03, metric.WithAttributes(
+			attribute.String("http.route", "/cart/{id}"),
+			attribute.String("url.full", url),
+		))
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(rm.ScopeMetrics[0].Metrics); n != 1 {
+		t.Fatalf("got %d streams, want 1", n)
+	}
+	dps := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Histogram[float64]).DataPoints
+	if len(dps) != 1 {
+		t.Fatalf("got %d series, want 1", len(dps))
+	}
+	if _, ok := dps[0].Attributes.Value("url.full"); ok {
+		t.Error("url.full was kept")
+	}
+	if route, _ := dps[0].Attributes.Value("http.route"); route.AsString() != "/cart/{id}" {
+		t.Errorf("http.route = %q, want %q", route.AsString(), "/cart/{id}")
+	}
+	if !slices.Equal(dps[0].Bounds, latencyBucketBoundaries) {
+		t.Errorf("bounds = %v, want %v", dps[0].Bounds, latencyBucketBoundaries)
+	}
+}
+
+func TestValidateUnit(t *testing.T) {
+	tests := []struct {
+		unit    string
+		wantErr string
+	}{
+		{unit: "s"},
+		{unit: "By/s"},
+		{unit: "{request}"},
+		{unit: strings.Repeat("s", 64), wantErr: "longer than 63 characters"},
+		{unit: "milli seconds", wantErr: "invalid character ' '"},
+		{unit: "µs", wantErr: "invalid character 'µ'"},
+		{unit: "{request", wantErr: "unmatched '{'"},
+		{unit: "request}", wantErr: "unmatched '}'"},
+	}
+	for _, tt := range tests {
+		err := validateUnit(tt.unit)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("validateUnit(%q) = %v, want nil", tt.unit, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("validateUnit(%q) = %v, want an error containing %q", tt.unit, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// job is a unit of work handed to the background worker.
+type job struct {
+	ID int64
+	// EnqueuedAt is when the job entered the queue, used to measure how long
+	// it waited for the worker.
+	EnqueuedAt time.Time
+	// Carrier holds the trace context of the request that produced the job, so
+	// the worker can continue the same trace.
+	Carrier propagation.MapCarrier
+}
+
+// enqueueJob adds a new job carrying the trace context in ctx to the queue
+// without blocking, returning false when the queue is full.
+func (a *App) enqueueJob(ctx context.Context) (job, bool) {
+	j := job{ID: a.lastJobID.Add(1), EnqueuedAt: time.Now(), Carrier: propagation.MapCarrier{}}
+	otel.GetTextMapPropagator().Inject(ctx, j.Carrier)
+
+	select {
+	case a.jobs <- j:
+		return j, true
+	default:
+		return j, false
+	}
+}
+
+// runWorker processes queued jobs until ctx is canceled.
+func (a *App) runWorker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-a.jobs:
+			a.processJob(ctx, j)
+		}
+	}
+}
+
+// processJob handles a single job, demonstrating instrumentation of work that
+// isn't driven by an HTTP request. The span continues the producer's trace
+// when the job carries one and is a new root otherwise.
+func (a *App) processJob(ctx context.Context, j job) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, j.Carrier)
+	ctx, span := a.tracer.Start(ctx, "processJob",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attribute.Int64("worker.job.id", j.ID)),
+	)
+	defer span.End()
+
+	start := time.Now()
+
+	// Time spent waiting in the queue reveals a growing backlog
+	queueWait := start.Sub(j.EnqueuedAt).Seconds()
+	a.jobQueueWaitHistogram.Record(ctx, queueWait)
+	span.SetAttributes(attribute.Float64("worker.job.queue_wait_seconds", queueWait))
+
+	// Simulate 10-50ms of work
+	time.Sleep(time.Duration(10+rand.IntN(40)) * time.Millisecond)
+
+	a.jobsProcessedCounter.Add(ctx, 1)
+	a.jobDurationHistogram.Record(ctx, time.Since(start).Seconds())
+}
+
+// enqueueHandler queues a job for the background worker. The job carries the
+// request's trace context, so the worker's span joins this request's trace.
+func (a *App) enqueueHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	if !a.requireMethod(w, r, span, http.MethodPost) {
+		return
+	}
+
+	j, ok := a.enqueueJob(ctx)
+	span.SetAttributes(attribute.Int64("worker.job.id", j.ID))
+	if !ok {
+		span.SetAttributes(attribute.Bool("enqueueHandler.error", true))
+		recordError(span, errors.New("job queue is full"))
+		span.SetStatus(codes.Error, "job queue is full")
+		http.Error(w, "Job queue is full", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	_, _ = fmt.Fprintf(w, "Job %d enqueued.", j.ID)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// dequeueJob returns the job at the head of app's queue, failing the test
+// when the queue is empty.
+func dequeueJob(t *testing.T, app *App) job {
+	t.Helper()
+
+	select {
+	case j := <-app.jobs:
+		return j
+	default:
+		t.Fatal("the job queue is empty")
+		return job{}
+	}
+}
+
+// usePropagator installs the propagator main installs for the duration of the
+// test.
+func usePropagator(t *testing.T) {
+	orig := otel.GetTextMapPropagator()
+	t.Cleanup(func() { otel.SetTextMapPropagator(orig) })
+	otel.SetTextMapPropagator(propagator)
+}
+
+func TestWorkerProcessesJobs(t *testing.T) {
+	app, spans, reader := newTestApp(t, config{})
+
+	for range 2 {
+		if _, ok := app.enqueueJob(context.Background()); !ok {
+			t.Fatal("enqueueJob reported a full queue")
+		}
+	}
+	for range 2 {
+		app.processJob(context.Background(), dequeueJob(t, app))
+	}
+
+	var processed int
+	for _, span := range spans.Ended() {
+		if span.Name() == "processJob" {
+			processed++
+		}
+	}
+	if processed != 2 {
+		t.Errorf("got %d processJob spans, want 2", processed)
+	}
+	if n := collectInt64Sum(t, reader, "worker.jobs.processed"); n != 2 {
+		t.Errorf("worker.jobs.processed = %d, want 2", n)
+	}
+}
+
+func TestWorkerJoinsEnqueueTrace(t *testing.T) {
+	usePropagator(t)
+	app, spans, _ := newTestApp(t, config{})
+
+	rec := httptest.NewRecorder()
+	app.otelMiddleware(app.enqueueHandler, "enqueueHandler")(rec, httptest.NewRequest(http.MethodPost, "/enqueue", nil))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusAccepted)
+	}
+	app.processJob(context.Background(), dequeueJob(t, app))
+
+	request := endedSpan(t, spans, "enqueueHandler")
+	worker := endedSpan(t, spans, "processJob")
+	if worker.SpanContext().TraceID() != request.SpanContext().TraceID() {
+		t.Errorf("worker trace = %s, want the enqueue trace %s", worker.SpanContext().TraceID(), request.SpanContext().TraceID())
+	}
+	if worker.Parent().SpanID() != request.SpanContext().SpanID() {
+		t.Errorf("worker parent = %s, want the enqueue span %s", worker.Parent().SpanID(), request.SpanContext().SpanID())
+	}
+}
+
+func TestWorkerQueueWait(t *testing.T) {
+	app, spans, reader := newTestApp(t, config{})
+
+	if _, ok := app.enqueueJob(context.Background()); !ok {
+		t.Fatal("the job queue is full")
+	}
+	const delay = 30 * time.Millisecond
+	time.Sleep(delay)
+	app.processJob(context.Background(), dequeueJob(t, app))
+
+	attrs := attribute.NewSet(endedSpan(t, spans, "processJob").Attributes()...)
+	if wait, _ := attrs.Value("worker.job.queue_wait_seconds"); wait.AsFloat64() < delay.Seconds() {
+		t.Errorf("span queue wait = %vs, want at least %vs", wait.AsFloat64(), delay.Seconds())
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "worker.job.queue_wait_seconds" {
+				continue
+			}
+			dp := m.Data.(metricdata.Histogram[float64]).DataPoints[0]
+			if dp.Count != 1 || dp.Sum < delay.Seconds() {
+				t.Errorf("recorded %d waits totaling %vs, want 1 of at least %vs", dp.Count, dp.Sum, delay.Seconds())
+			}
+			return
+		}
+	}
+	t.Error("no worker.job.queue_wait_seconds metric was collected")
+}
+
+func TestEnqueueFullQueue(t *testing.T) {
+	app, spans, _ := newTestApp(t, config{})
+
+	for range cap(app.jobs) {
+		if _, ok := app.enqueueJob(context.Background()); !ok {
+			t.Fatal("enqueueJob reported a full queue before it was full")
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	app.otelMiddleware(app.enqueueHandler, "enqueueHandler")(rec, httptest.NewRequest(http.MethodPost, "/enqueue", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	if status := endedSpan(t, spans, "enqueueHandler").Status(); status.Code != codes.Error {
+		t.Errorf("span status = %v, want %v", status.Code, codes.Error)
+	}
+}
+
+func TestRecordErrorStackTrace(t *testing.T) {
+	defer func(orig bool) { recordStackTraces = orig }(recordStackTraces)
+
+	for _, enabled := range []bool{true, false} {
+		recordStackTraces = enabled
+		app, spans, _ := newTestApp(t, config{})
+		for range cap(app.jobs) {
+			app.enqueueJob(context.Background())
+		}
+		app.otelMiddleware(app.enqueueHandler, "enqueueHandler")(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/enqueue", nil))
+
+		var exception *sdktrace.Event
+		for _, event := range endedSpan(t, spans, "enqueueHandler").Events() {
+			if event.Name == "exception" {
+				exception = &event
+			}
+		}
+		if exception == nil {
+			t.Fatal("no exception event was recorded")
+		}
+		attrs := attribute.NewSet(exception.Attributes...)
+		if got := attrs.HasValue("exception.stacktrace"); got != enabled {
+			t.Errorf("with stack traces enabled=%t, exception has a stack trace = %t", enabled, got)
+		}
+	}
+}